@@ -0,0 +1,365 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// batchRow is one artist of a batch file with its author and filters.
+type batchRow struct {
+	AuthorId uint
+	Filters  services.SearchFilters
+}
+
+// batchColumns are the fields a row may set; only artist is required.
+var batchColumns = []string{"artist", "author_id", "type", "format", "year_from", "year_to", "genre", "style", "country", "label"}
+
+// batchResult is the part of the report covering one artist.
+type batchResult struct {
+	Artist   string      `json:"artist"`
+	AuthorId uint        `json:"authorId"`
+	Found    int         `json:"found"`
+	Imported int         `json:"imported"`
+	Failed   int         `json:"failed"`
+	Error    string      `json:"error,omitempty"`
+	Items    []batchItem `json:"items,omitempty"`
+	results  []services.ItemResult
+}
+
+type batchItem struct {
+	Title      string `json:"title"`
+	RequestId  string `json:"requestId"`
+	Error      string `json:"error,omitempty"`
+	CoverError string `json:"coverError,omitempty"`
+}
+
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: GoFetcher batch [flags] artists.csv|artists.json|artists.txt|-")
+		fs.PrintDefaults()
+	}
+	var imageOpts services.ImageOptions
+	cacheDir := fs.String("cache-dir", services.DefaultCacheDir(), "directory used to cache downloaded cover images")
+	fs.IntVar(&imageOpts.MaxDimension, "image-max-size", 0, "scale cover images down to this many pixels on the longest side (0 keeps the original size)")
+	fs.StringVar(&imageOpts.Format, "image-format", "", "re-encode cover images as jpeg or png (empty keeps the original format)")
+	fs.IntVar(&imageOpts.Quality, "image-quality", 0, "jpeg quality used when re-encoding cover images")
+	fs.Int64Var(&imageOpts.MaxBytes, "image-max-bytes", 20<<20, "reject cover images larger than this many bytes (0 disables the limit)")
+	fs.BoolVar(&imageOpts.AllowMissing, "allow-missing-cover", false, "import records without a cover when the image cannot be downloaded")
+	coverMode := fs.String("cover-mode", string(services.CoverFile), "how covers are uploaded: file, stream or url")
+	discogsToken := fs.String("discogs-token", "", "Discogs personal access token (defaults to $DISCOGS_TOKEN)")
+	authorId := fs.Uint("author", 0, "author ID used for rows that do not set author_id")
+	mediaUrl := fs.String("media-url", services.MediaServiceUrl, "media service endpoint records are uploaded to")
+	token := fs.String("token", os.Getenv("GOFETCHER_TOKEN"), "media service token (defaults to $GOFETCHER_TOKEN)")
+	searchType := fs.String("type", "master", "Discogs search type used for rows that do not set one")
+	limit := fs.Int("limit", 0, "import at most this many records per artist (0 imports all)")
+	rateLimit := fs.Int("rate-limit", 55, "maximum Discogs requests per minute across all artists (0 disables the limit)")
+	reportPath := fs.String("report", "", "write a JSON report of every artist and item to this file")
+	dryRun := fs.Bool("dry-run", false, "fetch and map the records without uploading them")
+	exportPath := fs.String("export", "", "write the mapped records of every artist to this file")
+	exportFormat := fs.String("export-format", "", "export format: json, ndjson or csv (defaults to the file extension)")
+	exportColumns := fs.String("export-columns", "", "comma separated columns to export (defaults to "+strings.Join(services.ExportColumns, ",")+")")
+	exportRaw := fs.Bool("export-raw", false, "include the raw Discogs master of each record in the export")
+	applyLog := logFlags(fs, "", true)
+	applyReplay := replayFlags(fs)
+	applyTrace := traceFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyLog(); err != nil {
+		return err
+	}
+	_, writeTrace := applyTrace()
+	defer func() {
+		if err := writeTrace(); err != nil {
+			slog.Error("writing trace failed", "err", err)
+		}
+	}()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("batch needs exactly one input file")
+	}
+	mode, err := services.ParseCoverMode(*coverMode)
+	if err != nil {
+		return err
+	}
+	exportOpts, err := parseExportOptions(*exportFormat, *exportColumns, *exportRaw)
+	if err != nil {
+		return err
+	}
+
+	rows, err := readBatchFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	fetcher, err := newFetcher(mode, *cacheDir, imageOpts)
+	if err != nil {
+		return err
+	}
+	var sink services.Sink
+	if !*dryRun {
+		sink = &services.MediaService{Url: *mediaUrl, Mode: mode, Images: imageOpts}
+	}
+	services.SetRateLimit(*rateLimit)
+	if err := applyReplay(); err != nil {
+		return err
+	}
+	discogs := services.NewDiscogs(resolveDiscogsToken(*discogsToken))
+
+	var report []batchResult
+	var exported []services.ExportRecord
+	for i, row := range rows {
+		if row.AuthorId == 0 {
+			row.AuthorId = uint(*authorId)
+		}
+		if row.Filters.Type == "" {
+			row.Filters.Type = *searchType
+		}
+		fmt.Printf("[%d/%d] %s\n", i+1, len(rows), row.Filters.Query)
+		result := batchResult{Artist: row.Filters.Query, AuthorId: row.AuthorId}
+		records, err := discogs.Search(row.Filters)
+		if *limit > 0 && len(records) > *limit {
+			records = records[:*limit]
+		}
+		result.Found = len(records)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case row.AuthorId == 0 && !*dryRun:
+			result.Error = "no author ID, set -author or the author_id column"
+		default:
+			result.results = services.Import(records, services.ImportOptions{
+				AuthorId: row.AuthorId,
+				Token:    *token,
+				Fetcher:  fetcher,
+				Sink:     sink,
+			})
+			exported = append(exported, services.ExportRecords(result.results)...)
+		}
+		result.summarize(!*dryRun)
+		for _, item := range result.results {
+			fmt.Println("  " + item.String())
+		}
+		if result.Error != "" {
+			fmt.Println("  ✗ " + result.Error)
+		}
+		report = append(report, result)
+	}
+
+	var imported, failed int
+	for _, result := range report {
+		imported += result.Imported
+		failed += result.Failed
+	}
+	if *dryRun {
+		fmt.Printf("Fetched %d records for %d artists without uploading, %d failed\n", len(exported), len(report), failed)
+	} else {
+		fmt.Printf("Imported %d records for %d artists, %d failed\n", imported, len(report), failed)
+	}
+	if *exportPath != "" {
+		if err := services.ExportFile(*exportPath, exported, exportOpts); err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d records to %s\n", len(exported), *exportPath)
+	}
+	if *reportPath != "" {
+		if err := writeBatchReport(*reportPath, report); err != nil {
+			return err
+		}
+		fmt.Println("Report written to", *reportPath)
+	}
+	return nil
+}
+
+// summarize counts items as imported only when they were uploaded.
+func (r *batchResult) summarize(uploaded bool) {
+	for _, item := range r.results {
+		entry := batchItem{Title: item.Title, RequestId: item.RequestId}
+		if item.Err != nil {
+			entry.Error = item.Err.Error()
+			r.Failed++
+		} else if uploaded {
+			r.Imported++
+		}
+		if item.CoverErr != nil {
+			entry.CoverError = item.CoverErr.Error()
+		}
+		r.Items = append(r.Items, entry)
+	}
+}
+
+func writeBatchReport(path string, report []batchResult) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// readBatchFile reads path, or stdin for "-", as CSV, JSON or one artist per line.
+func readBatchFile(path string) ([]batchRow, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var rows []batchRow
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = readBatchCSV(r)
+	case ".json":
+		rows, err = readBatchJSON(r)
+	default:
+		rows, err = readBatchText(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no artists in %s", path)
+	}
+	return rows, nil
+}
+
+// readBatchText skips blank lines and # comments.
+func readBatchText(r io.Reader) ([]batchRow, error) {
+	var rows []batchRow
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, batchRow{Filters: services.SearchFilters{Query: line}})
+	}
+	return rows, scanner.Err()
+}
+
+// readBatchCSV reads a CSV file whose header names some of batchColumns.
+func readBatchCSV(r io.Reader) ([]batchRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+	var rows []batchRow
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := make(map[string]string)
+		for i, value := range values {
+			if i < len(header) {
+				fields[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		row, err := newBatchRow(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readBatchJSON reads artist names or objects keyed by batchColumns.
+func readBatchJSON(r io.Reader) ([]batchRow, error) {
+	var entries []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+	var rows []batchRow
+	for i, entry := range entries {
+		var artist string
+		if err := json.Unmarshal(entry, &artist); err == nil {
+			rows = append(rows, batchRow{Filters: services.SearchFilters{Query: artist}})
+			continue
+		}
+		var object map[string]any
+		if err := json.Unmarshal(entry, &object); err != nil {
+			return nil, fmt.Errorf("entry %d: want an artist name or an object", i+1)
+		}
+		fields := make(map[string]string)
+		for k, v := range object {
+			switch v := v.(type) {
+			case nil:
+				fields[k] = ""
+			case string:
+				fields[k] = strings.TrimSpace(v)
+			case float64:
+				fields[k] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return nil, fmt.Errorf("entry %d: %s must be a string or a number", i+1, k)
+			}
+		}
+		row, err := newBatchRow(fields)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func newBatchRow(fields map[string]string) (batchRow, error) {
+	for name := range fields {
+		if !isBatchColumn(name) {
+			return batchRow{}, fmt.Errorf("unknown column %q (want %s)", name, strings.Join(batchColumns, ", "))
+		}
+	}
+	row := batchRow{Filters: services.SearchFilters{
+		Query:    fields["artist"],
+		Type:     fields["type"],
+		Format:   fields["format"],
+		YearFrom: fields["year_from"],
+		YearTo:   fields["year_to"],
+		Genre:    fields["genre"],
+		Style:    fields["style"],
+		Country:  fields["country"],
+		Label:    fields["label"],
+	}}
+	if row.Filters.Query == "" {
+		return row, errors.New("missing artist")
+	}
+	if id := fields["author_id"]; id != "" {
+		n, err := strconv.ParseUint(id, 10, 0)
+		if err != nil || n == 0 {
+			return row, fmt.Errorf("invalid author_id %q", id)
+		}
+		row.AuthorId = uint(n)
+	}
+	return row, nil
+}
+
+func isBatchColumn(name string) bool {
+	for _, column := range batchColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
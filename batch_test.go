@@ -0,0 +1,111 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBatch(t *testing.T) {
+	sonicYouth := batchRow{Filters: services.SearchFilters{Query: "Sonic Youth"}}
+	tests := []struct {
+		name  string
+		read  func(string) ([]batchRow, error)
+		input string
+		want  []batchRow
+		err   string
+	}{
+		{
+			name:  "text",
+			read:  batchText,
+			input: "# artists\nSonic Youth\n\n  Pixies  \n",
+			want:  []batchRow{sonicYouth, {Filters: services.SearchFilters{Query: "Pixies"}}},
+		},
+		{
+			name:  "csv",
+			read:  batchCSV,
+			input: "Artist,author_id,year_from,year_to,genre\nSonic Youth,,,,\nPixies, 7 ,1987,1991,Rock\n",
+			want: []batchRow{sonicYouth, {AuthorId: 7, Filters: services.SearchFilters{
+				Query: "Pixies", YearFrom: "1987", YearTo: "1991", Genre: "Rock",
+			}}},
+		},
+		{
+			name:  "csv unknown column",
+			read:  batchCSV,
+			input: "artist,colour\nPixies,red\n",
+			err:   `line 2: unknown column "colour"`,
+		},
+		{
+			name:  "csv invalid author",
+			read:  batchCSV,
+			input: "artist,author_id\nPixies,0\n",
+			err:   `line 2: invalid author_id "0"`,
+		},
+		{
+			name:  "csv missing artist",
+			read:  batchCSV,
+			input: "artist,type\n,master\n",
+			err:   "line 2: missing artist",
+		},
+		{
+			name:  "json",
+			read:  batchJSON,
+			input: `["Sonic Youth", {"artist": "Pixies", "author_id": 1000000, "year_from": 1987, "label": null, "type": "master"}]`,
+			want: []batchRow{sonicYouth, {AuthorId: 1000000, Filters: services.SearchFilters{
+				Query: "Pixies", YearFrom: "1987", Type: "master",
+			}}},
+		},
+		{
+			name:  "json string author",
+			read:  batchJSON,
+			input: `[{"artist": "Pixies", "author_id": "7"}]`,
+			want:  []batchRow{{AuthorId: 7, Filters: services.SearchFilters{Query: "Pixies"}}},
+		},
+		{
+			name:  "json fractional author",
+			read:  batchJSON,
+			input: `[{"artist": "Pixies", "author_id": 7.5}]`,
+			err:   `entry 1: invalid author_id "7.5"`,
+		},
+		{
+			name:  "json null author",
+			read:  batchJSON,
+			input: `[{"artist": "Pixies", "author_id": null}]`,
+			want:  []batchRow{{Filters: services.SearchFilters{Query: "Pixies"}}},
+		},
+		{
+			name:  "json nested value",
+			read:  batchJSON,
+			input: `[{"artist": "Pixies", "genre": ["Rock"]}]`,
+			err:   "entry 1: genre must be a string or a number",
+		},
+		{
+			name:  "json neither name nor object",
+			read:  batchJSON,
+			input: `[7]`,
+			err:   "entry 1: want an artist name or an object",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.read(tt.input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v; want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func batchText(s string) ([]batchRow, error) { return readBatchText(strings.NewReader(s)) }
+func batchCSV(s string) ([]batchRow, error)  { return readBatchCSV(strings.NewReader(s)) }
+func batchJSON(s string) ([]batchRow, error) { return readBatchJSON(strings.NewReader(s)) }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+)
+
+func runCache(args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		return errors.New("usage: GoFetcher cache prune [-cache-dir dir] [-older-than duration]")
+	}
+	fs := flag.NewFlagSet("cache prune", flag.ExitOnError)
+	cacheDir := fs.String("cache-dir", services.DefaultCacheDir(), "directory used to cache downloaded cover images")
+	olderThan := fs.Duration("older-than", 0, "only remove images not used for this long (0 removes everything)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cache, err := services.NewImageCache(*cacheDir, services.ImageOptions{})
+	if err != nil {
+		return err
+	}
+	removed, freed, err := cache.Prune(*olderThan)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d cached images (%d bytes) from %s\n", removed, freed, cache.Dir)
+	return nil
+}
+
+// replayFlags adds the replay flags to fs; call the result once fs is parsed.
+func replayFlags(fs *flag.FlagSet) func() error {
+	mode := fs.String("replay", string(services.ReplayOff), "off, record (store every Discogs response) or replay (serve stored responses offline)")
+	dir := fs.String("replay-dir", filepath.Join("testdata", "discogs"), "directory holding recorded Discogs responses")
+	return func() error {
+		replayMode, err := services.ParseReplayMode(*mode)
+		if err != nil {
+			return err
+		}
+		return services.SetReplay(*dir, replayMode)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type graphicsProtocol int8
+
+const (
+	graphicsHalfBlock graphicsProtocol = iota
+	graphicsKitty
+	graphicsSixel
+	graphicsNone
+)
+
+// Approximate size of a terminal cell in pixels, used to size sixel images.
+const (
+	cellWidth  = 10
+	cellHeight = 20
+)
+
+// graphicsOut bypasses the renderer, which would truncate image sequences.
+var graphicsOut io.Writer = os.Stdout
+
+// detectGraphics picks the image protocol; GOFETCHER_GRAPHICS overrides it.
+func detectGraphics(getenv func(string) string) graphicsProtocol {
+	switch getenv("GOFETCHER_GRAPHICS") {
+	case "kitty":
+		return graphicsKitty
+	case "sixel":
+		return graphicsSixel
+	case "halfblock":
+		return graphicsHalfBlock
+	case "none":
+		return graphicsNone
+	}
+	term, program := getenv("TERM"), getenv("TERM_PROGRAM")
+	switch {
+	case getenv("NO_COLOR") != "":
+		// Images are all colour, so NO_COLOR turns them off
+		return graphicsNone
+	case getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", program == "ghostty":
+		return graphicsKitty
+	case strings.Contains(term, "sixel"), term == "foot", strings.HasPrefix(term, "mlterm"), program == "WezTerm":
+		return graphicsSixel
+	}
+	return graphicsHalfBlock
+}
+
+// coverSize keeps the cover square, with cells twice as tall as wide.
+func coverSize(width, height int) (int, int) {
+	cols := width
+	rows := cols / 2
+	if rows > height {
+		rows = height
+		cols = rows * 2
+	}
+	return cols, rows
+}
+
+// scaleTo resizes img to exactly w×h pixels using nearest neighbour sampling.
+func scaleTo(img image.Image, w, h int) *image.RGBA {
+	src := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := src.Min.Y + y*src.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := src.Min.X + x*src.Dx()/w
+			dst.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// renderHalfBlock draws two pixels per cell with the upper half block.
+func renderHalfBlock(img image.Image, cols, rows int) string {
+	if cols <= 0 || rows <= 0 {
+		return ""
+	}
+	scaled := scaleTo(img, cols, rows*2)
+	var b strings.Builder
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			top := scaled.RGBAAt(x, y*2)
+			bottom := scaled.RGBAAt(x, y*2+1)
+			fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀",
+				top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		b.WriteString("\x1b[0m")
+		if y < rows-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// kittyTransmit uploads img with a virtual placement for kittyPlaceholder.
+func kittyTransmit(id uint32, img image.Image, cols, rows int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var b strings.Builder
+	const chunk = 4096
+	for i := 0; i < len(data); i += chunk {
+		end := min(i+chunk, len(data))
+		more := 0
+		if end < len(data) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&b, "\x1b_Ga=T,U=1,q=2,f=100,i=%d,c=%d,r=%d,m=%d;%s\x1b\\", id, cols, rows, more, data[i:end])
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%d;%s\x1b\\", more, data[i:end])
+		}
+	}
+	return b.String(), nil
+}
+
+// kittyDiacritics number the placeholder rows and columns.
+var kittyDiacritics = []rune{
+	0x0305, 0x030D, 0x030E, 0x0310, 0x0312, 0x033D, 0x033E, 0x033F, 0x0346, 0x034A,
+	0x034B, 0x034C, 0x0350, 0x0351, 0x0352, 0x0357, 0x035B, 0x0363, 0x0364, 0x0365,
+	0x0366, 0x0367, 0x0368, 0x0369, 0x036A, 0x036B, 0x036C, 0x036D, 0x036E, 0x036F,
+	0x0483, 0x0484, 0x0485, 0x0486, 0x0487, 0x0592, 0x0593, 0x0594, 0x0595, 0x0597,
+	0x0598, 0x0599, 0x059C, 0x059D, 0x059E, 0x059F, 0x05A0, 0x05A1, 0x05A8, 0x05A9,
+	0x05AB, 0x05AC, 0x05AF, 0x05C4, 0x0610, 0x0611, 0x0612, 0x0613, 0x0614, 0x0615,
+	0x0616, 0x0617, 0x0657, 0x0658, 0x0659, 0x065A, 0x065B, 0x065D, 0x065E, 0x06D6,
+	0x06D7, 0x06D8, 0x06D9, 0x06DA, 0x06DB, 0x06DC, 0x06DF, 0x06E0, 0x06E1, 0x06E2,
+}
+
+// kittyPlaceholder renders the cells showing image id, carried in the colour.
+func kittyPlaceholder(id uint32, cols, rows int) string {
+	cols = min(cols, len(kittyDiacritics))
+	rows = min(rows, len(kittyDiacritics))
+	var b strings.Builder
+	for y := 0; y < rows; y++ {
+		fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm", byte(id>>16), byte(id>>8), byte(id))
+		for x := 0; x < cols; x++ {
+			b.WriteRune(0x10EEEE)
+			b.WriteRune(kittyDiacritics[y])
+			b.WriteRune(kittyDiacritics[x])
+		}
+		b.WriteString("\x1b[39m")
+		if y < rows-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// encodeSixel converts img to w×h sixels in the Plan 9 palette.
+func encodeSixel(img image.Image, w, h int) string {
+	paletted := image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), scaleTo(img, w, h), image.Point{})
+
+	var b strings.Builder
+	b.WriteString("\x1bPq")
+	fmt.Fprintf(&b, "\"1;1;%d;%d", w, h)
+	for i, c := range paletted.Palette {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&b, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+	for y0 := 0; y0 < h; y0 += 6 {
+		used := make(map[uint8]bool)
+		for y := y0; y < min(y0+6, h); y++ {
+			for x := 0; x < w; x++ {
+				used[paletted.ColorIndexAt(x, y)] = true
+			}
+		}
+		first := true
+		for i := range paletted.Palette {
+			if !used[uint8(i)] {
+				continue
+			}
+			if !first {
+				b.WriteString("$")
+			}
+			first = false
+			fmt.Fprintf(&b, "#%d", i)
+			writeSixelRow(&b, paletted, uint8(i), y0, w, h)
+		}
+		b.WriteString("-")
+	}
+	b.WriteString("\x1b\\")
+	return b.String()
+}
+
+// writeSixelRow writes one colour of a six pixel band, run-length encoded.
+func writeSixelRow(b *strings.Builder, img *image.Paletted, index uint8, y0, w, h int) {
+	var last byte
+	run := 0
+	flush := func() {
+		switch {
+		case run > 3:
+			fmt.Fprintf(b, "!%d%c", run, last)
+		case run > 0:
+			b.WriteString(strings.Repeat(string(last), run))
+		}
+	}
+	for x := 0; x < w; x++ {
+		var bits byte
+		for dy := 0; dy < 6 && y0+dy < h; dy++ {
+			if img.ColorIndexAt(x, y0+dy) == index {
+				bits |= 1 << dy
+			}
+		}
+		c := 63 + bits
+		if c == last && run > 0 {
+			run++
+			continue
+		}
+		flush()
+		last, run = c, 1
+	}
+	flush()
+}
+
+type sixelDrawMsg struct {
+	url string
+}
+
+// drawSixelLater lets the renderer flush the pane before painting over it.
+func drawSixelLater(url string) tea.Cmd {
+	return tea.Tick(50*time.Millisecond, func(time.Time) tea.Msg {
+		return sixelDrawMsg{url: url}
+	})
+}
+
+// writeGraphics writes seq, at the 1-based cell row, col when row > 0.
+func writeGraphics(seq string, row, col int) tea.Cmd {
+	return func() tea.Msg {
+		if row > 0 {
+			seq = fmt.Sprintf("\x1b7\x1b[%d;%dH%s\x1b8", row, col, seq)
+		}
+		_, _ = io.WriteString(graphicsOut, seq)
+		return nil
+	}
+}
+
+// blank reserves cols×rows cells for an image drawn on top.
+func blank(cols, rows int) string {
+	line := strings.Repeat(" ", cols)
+	lines := make([]string, rows)
+	for i := range lines {
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// decodeCover flattens transparency onto black for the half-block output.
+func decodeCover(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	opaque := image.NewRGBA(bounds)
+	draw.Draw(opaque, bounds, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	draw.Draw(opaque, bounds, img, bounds.Min, draw.Over)
+	return opaque, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testCover is a 16×16 image with a red to blue gradient across and a green
+// ramp down, so that every cell of the output differs.
+func testCover() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(255 - x*16), G: uint8(y * 16), B: uint8(x * 16), A: 255})
+		}
+	}
+	return img
+}
+
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output does not match %s\ngot:\n%q\nwant:\n%q", path, got, want)
+	}
+}
+
+func TestRenderHalfBlock(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		cols, rows int
+	}{
+		{"halfblock_8x4.golden", 8, 4},
+		{"halfblock_4x2.golden", 4, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			golden(t, tc.name, renderHalfBlock(testCover(), tc.cols, tc.rows))
+		})
+	}
+}
+
+func TestRenderHalfBlockEmpty(t *testing.T) {
+	if got := renderHalfBlock(testCover(), 0, 4); got != "" {
+		t.Errorf("expected no output for a zero sized pane, got %q", got)
+	}
+}
+
+func TestCoverSize(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		cols, rows    int
+	}{
+		{40, 30, 40, 20},
+		{40, 10, 20, 10},
+		{7, 30, 7, 3},
+	} {
+		cols, rows := coverSize(tc.width, tc.height)
+		if cols != tc.cols || rows != tc.rows {
+			t.Errorf("coverSize(%d, %d) = %d, %d; want %d, %d", tc.width, tc.height, cols, rows, tc.cols, tc.rows)
+		}
+	}
+}
+
+func TestDetectGraphics(t *testing.T) {
+	for _, tc := range []struct {
+		env  map[string]string
+		want graphicsProtocol
+	}{
+		{map[string]string{"TERM": "xterm-256color"}, graphicsHalfBlock},
+		{map[string]string{"TERM": "xterm-kitty"}, graphicsKitty},
+		{map[string]string{"KITTY_WINDOW_ID": "1"}, graphicsKitty},
+		{map[string]string{"TERM": "foot"}, graphicsSixel},
+		{map[string]string{"TERM": "xterm-kitty", "GOFETCHER_GRAPHICS": "halfblock"}, graphicsHalfBlock},
+		{map[string]string{"GOFETCHER_GRAPHICS": "none"}, graphicsNone},
+		{map[string]string{"TERM": "xterm-kitty", "NO_COLOR": "1"}, graphicsNone},
+		{map[string]string{"NO_COLOR": "1", "GOFETCHER_GRAPHICS": "halfblock"}, graphicsHalfBlock},
+	} {
+		got := detectGraphics(func(key string) string { return tc.env[key] })
+		if got != tc.want {
+			t.Errorf("detectGraphics(%v) = %d; want %d", tc.env, got, tc.want)
+		}
+	}
+}
+
+func TestKittyCoverWaitsForWindowSize(t *testing.T) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, testCover()); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel()
+	m.graphics = graphicsKitty
+	m.Update(detailsMsg{url: "a", details: services.Details{Thumb: &services.Image{Data: data.Bytes()}}})
+	if c := m.covers["a"]; c == nil || c.kittyId != 0 {
+		t.Fatalf("cover = %+v; want it kept but not transmitted before the pane is sized", c)
+	}
+	if _, cmd := m.Update(tea.WindowSizeMsg{Width: 100, Height: 40}); cmd == nil {
+		t.Fatal("no command after the window was sized")
+	}
+	if c := m.covers["a"]; c.kittyId == 0 || c.cols <= 0 || c.rows <= 0 {
+		t.Errorf("cover = %+v; want it transmitted at the pane size", c)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// checkboxDelegate prefixes each title with its selection checkbox.
+type checkboxDelegate struct {
+	list.DefaultDelegate
+	selected map[string]bool
+}
+
+func newCheckboxDelegate(selected map[string]bool) checkboxDelegate {
+	return checkboxDelegate{
+		DefaultDelegate: list.NewDefaultDelegate(),
+		selected:        selected,
+	}
+}
+
+type checkedItem struct {
+	list.DefaultItem
+	box string
+}
+
+func (i checkedItem) Title() string {
+	return i.box + i.DefaultItem.Title()
+}
+
+func (d checkboxDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	it, ok := item.(list.DefaultItem)
+	if !ok {
+		return
+	}
+	box := "[ ] "
+	if d.selected[selectionKey(it)] {
+		box = "[x] "
+	}
+	d.DefaultDelegate.Render(w, m, index, checkedItem{DefaultItem: it, box: box})
+}
+
+// selectionKey is the resource url, which Description returns.
+func selectionKey(item list.DefaultItem) string {
+	return item.Description()
+}
+
+func selectionTitle(selected, total int) string {
+	return fmt.Sprintf("%d/%d selected", selected, total)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"fmt"
+	"image"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type detailsMsg struct {
+	url     string
+	details services.Details
+	err     error
+}
+
+func fetchDetails(fetcher services.Fetcher, record services.Record) tea.Cmd {
+	return func() tea.Msg {
+		details, err := fetcher.Details(record)
+		return detailsMsg{url: record.Url(), details: details, err: err}
+	}
+}
+
+// loadDetails fetches the highlighted record's details unless already loading.
+func (m *model) loadDetails() tea.Cmd {
+	record, ok := m.list.SelectedItem().(services.Record)
+	if !ok {
+		return nil
+	}
+	if _, ok := m.details[record.Url()]; ok {
+		return nil
+	}
+	m.details[record.Url()] = nil
+	return fetchDetails(m.fetcher, record)
+}
+
+func (m *model) detailView() string {
+	record, ok := m.list.SelectedItem().(services.Record)
+	if !ok {
+		return ""
+	}
+	details := record.Details()
+	status := m.theme.Muted.Render("loading…")
+	if entry := m.details[record.Url()]; entry != nil {
+		details = entry.details
+		status = ""
+		if entry.err != nil {
+			status = m.theme.Error.Render("error: " + entry.err.Error())
+		}
+	}
+
+	join := func(values []string) string {
+		if len(values) == 0 {
+			return "-"
+		}
+		return strings.Join(values, ", ")
+	}
+	tracks := "-"
+	if details.Tracks >= 0 {
+		tracks = fmt.Sprint(details.Tracks)
+	}
+	width := max(m.paneWidth-m.theme.Detail.GetHorizontalFrameSize(), 10)
+
+	var b strings.Builder
+	if cover := m.coverView(record.Url()); cover != "" {
+		b.WriteString(cover + "\n\n")
+	}
+	b.WriteString(m.theme.Heading.Render(record.Title()) + "\n\n")
+	for _, row := range [][2]string{
+		{"Year", details.Year},
+		{"Genres", join(details.Genres)},
+		{"Styles", join(details.Styles)},
+		{"Format", join(details.Formats)},
+		{"Label", join(details.Labels)},
+		{"Tracks", tracks},
+	} {
+		b.WriteString(fmt.Sprintf("%-7s %s\n", row[0], row[1]))
+	}
+	if details.Thumb != nil {
+		b.WriteString("\nCover   " + details.Thumb.String() + "\n")
+	}
+	if status != "" {
+		b.WriteString("\n" + status + "\n")
+	}
+	return m.theme.Detail.Width(width + m.theme.Detail.GetHorizontalPadding()).Render(b.String())
+}
+
+type cover struct {
+	img        image.Image
+	kittyId    uint32
+	cols, rows int
+	rendered   string
+}
+
+// coverCells returns the size in cells available for the cover in the pane.
+func (m *model) coverCells() (int, int) {
+	cols := m.paneWidth - m.theme.Detail.GetHorizontalFrameSize()
+	// Leave room for the title and detail rows below the cover
+	rows := m.paneHeight - m.theme.Detail.GetVerticalFrameSize() - 12
+	return coverSize(min(cols, 40), rows)
+}
+
+// addCover decodes the thumbnail and, for kitty, transmits it.
+func (m *model) addCover(msg detailsMsg) tea.Cmd {
+	if m.graphics == graphicsNone || msg.details.Thumb == nil {
+		return nil
+	}
+	img, err := decodeCover(msg.details.Thumb.Data)
+	if err != nil {
+		return nil
+	}
+	c := &cover{img: img}
+	m.covers[msg.url] = c
+	switch m.graphics {
+	case graphicsKitty:
+		return m.transmitCover(msg.url, c)
+	case graphicsSixel:
+		return m.drawSixel()
+	}
+	return nil
+}
+
+// transmitCover waits for transmitPending until the pane has been sized.
+func (m *model) transmitCover(url string, c *cover) tea.Cmd {
+	cols, rows := m.coverCells()
+	if m.paneWidth <= 0 || cols <= 0 || rows <= 0 {
+		return nil
+	}
+	m.nextImageId++
+	c.kittyId = m.nextImageId
+	c.cols, c.rows = cols, rows
+	seq, err := kittyTransmit(c.kittyId, c.img, c.cols, c.rows)
+	if err != nil {
+		delete(m.covers, url)
+		return nil
+	}
+	return writeGraphics(seq, 0, 0)
+}
+
+func (m *model) transmitPending() tea.Cmd {
+	if m.graphics != graphicsKitty {
+		return nil
+	}
+	var cmds []tea.Cmd
+	for url, c := range m.covers {
+		if c.kittyId == 0 {
+			cmds = append(cmds, m.transmitCover(url, c))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// drawSixel schedules painting the cover of the highlighted record.
+func (m *model) drawSixel() tea.Cmd {
+	record, ok := m.list.SelectedItem().(services.Record)
+	if m.graphics != graphicsSixel || !ok || m.covers[record.Url()] == nil {
+		return nil
+	}
+	return drawSixelLater(record.Url())
+}
+
+func (m *model) coverView(url string) string {
+	c := m.covers[url]
+	if c == nil {
+		return ""
+	}
+	cols, rows := m.coverCells()
+	if cols <= 0 || rows <= 0 {
+		return ""
+	}
+	switch m.graphics {
+	case graphicsKitty:
+		if c.kittyId == 0 {
+			return ""
+		}
+		if c.cols <= cols && c.rows <= rows {
+			return kittyPlaceholder(c.kittyId, c.cols, c.rows)
+		}
+	case graphicsSixel:
+		return blank(cols, rows)
+	}
+	// Half-block output is cached until the pane changes size
+	if c.rendered == "" || c.cols != cols || c.rows != rows {
+		c.cols, c.rows = cols, rows
+		c.rendered = renderHalfBlock(c.img, cols, rows)
+	}
+	return c.rendered
+}
+
+// sixelPosition returns the 1-based cell of the cover's top left corner.
+func (m *model) sixelPosition() (int, int) {
+	row := m.theme.Doc.GetMarginTop() + m.theme.Detail.GetBorderTopSize() + m.theme.Detail.GetPaddingTop() + 1
+	col := m.theme.Doc.GetMarginLeft() + m.list.Width() + m.theme.Detail.GetBorderLeftSize() + m.theme.Detail.GetPaddingLeft() + 1
+	return row, col
+}
+
+// releasesView lays out the release list next to the preview pane.
+func (m *model) releasesView() string {
+	return lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), m.detailView())
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type exportDoneMsg struct {
+	path  string
+	count int
+	err   error
+}
+
+// parseExportOptions checks the export flags of the TUI and batch command.
+func parseExportOptions(format, columns string, raw bool) (services.ExportOptions, error) {
+	opts := services.ExportOptions{Columns: services.ParseColumns(columns), Raw: raw}
+	if format != "" {
+		var err error
+		if opts.Format, err = services.ParseExportFormat(format); err != nil {
+			return opts, err
+		}
+	}
+	// Catch unknown columns before any work is done
+	if err := services.Export(io.Discard, nil, services.ExportOptions{Columns: opts.Columns}); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// exportResults writes the records of the last import to m.exportPath.
+func (m *model) exportResults() tea.Cmd {
+	path, opts := m.exportPath, m.exportOpts
+	records := services.ExportRecords(m.results)
+	return func() tea.Msg {
+		err := services.ExportFile(path, records, opts)
+		return exportDoneMsg{path: path, count: len(records), err: err}
+	}
+}
+
+// exportStatus describes the outcome of the last export on the Done screen.
+func (m *model) exportStatus() string {
+	switch {
+	case m.exported == nil:
+		return ""
+	case m.exported.err != nil:
+		return m.theme.Error.Render(m.exported.err.Error())
+	}
+	return m.theme.Success.Render(fmt.Sprintf("Exported %d records to %s", m.exported.count, m.exported.path))
+}
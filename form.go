@@ -0,0 +1,181 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var searchTypes = []string{"master", "release", "artist", "label"}
+
+const (
+	fieldQuery = iota
+	fieldType
+	fieldFormat
+	fieldYearFrom
+	fieldYearTo
+	fieldGenre
+	fieldStyle
+	fieldCountry
+	fieldLabel
+	fieldCount
+)
+
+var fieldLabels = [fieldCount]string{"Artist", "Type", "Format", "Year from", "Year to", "Genre", "Style", "Country", "Label"}
+
+// searchForm has one text input per filter and a toggle for the result type.
+type searchForm struct {
+	focus  int
+	kind   int
+	inputs [fieldCount]textinput.Model
+
+	// recent searches, most recent first, browsed from the artist field
+	recent  []services.SearchFilters
+	browsed int
+}
+
+func newSearchForm() searchForm {
+	var f searchForm
+	for i := range f.inputs {
+		ti := textinput.New()
+		ti.CharLimit = 1024
+		ti.Width = 30
+		ti.Prompt = ""
+		f.inputs[i] = ti
+	}
+	f.inputs[fieldFormat].SetValue("album")
+	f.inputs[fieldYearFrom].CharLimit = 4
+	f.inputs[fieldYearTo].CharLimit = 4
+	f.inputs[fieldYearFrom].Placeholder = "yyyy"
+	f.inputs[fieldYearTo].Placeholder = "yyyy"
+	f.inputs[fieldQuery].Focus()
+	f.browsed = -1
+	return f
+}
+
+func (f *searchForm) apply(filters services.SearchFilters) {
+	f.inputs[fieldQuery].SetValue(filters.Query)
+	f.inputs[fieldQuery].CursorEnd()
+	for i, kind := range searchTypes {
+		if kind == filters.Type {
+			f.kind = i
+		}
+	}
+	f.inputs[fieldFormat].SetValue(filters.Format)
+	f.inputs[fieldYearFrom].SetValue(filters.YearFrom)
+	f.inputs[fieldYearTo].SetValue(filters.YearTo)
+	f.inputs[fieldGenre].SetValue(filters.Genre)
+	f.inputs[fieldStyle].SetValue(filters.Style)
+	f.inputs[fieldCountry].SetValue(filters.Country)
+	f.inputs[fieldLabel].SetValue(filters.Label)
+}
+
+// browse steps through the recent searches, then back to an empty form.
+func (f *searchForm) browse(step int) {
+	if len(f.recent) == 0 {
+		return
+	}
+	f.browsed = max(-1, min(f.browsed+step, len(f.recent)-1))
+	if f.browsed < 0 {
+		f.apply(services.SearchFilters{Type: searchTypes[0], Format: "album"})
+		return
+	}
+	f.apply(f.recent[f.browsed])
+}
+
+func (f searchForm) Filters() services.SearchFilters {
+	value := func(i int) string { return strings.TrimSpace(f.inputs[i].Value()) }
+	return services.SearchFilters{
+		Query:    value(fieldQuery),
+		Type:     searchTypes[f.kind],
+		Format:   value(fieldFormat),
+		YearFrom: value(fieldYearFrom),
+		YearTo:   value(fieldYearTo),
+		Genre:    value(fieldGenre),
+		Style:    value(fieldStyle),
+		Country:  value(fieldCountry),
+		Label:    value(fieldLabel),
+	}
+}
+
+func (f searchForm) history() bool {
+	return f.focus == fieldQuery && len(f.recent) > 0
+}
+
+func (f searchForm) empty() bool {
+	return f.focus == fieldType || f.inputs[f.focus].Value() == ""
+}
+
+func (f *searchForm) setFocus(i int) tea.Cmd {
+	f.inputs[f.focus].Blur()
+	f.focus = (i + fieldCount) % fieldCount
+	if f.focus == fieldType {
+		return nil
+	}
+	return f.inputs[f.focus].Focus()
+}
+
+// Update moves between and edits fields; submitting is left to the caller.
+func (f searchForm) Update(msg tea.Msg, keys keyMap) (searchForm, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case f.history() && key.Matches(msg, keys.HistoryPrev):
+			f.browse(1)
+			return f, nil
+		case f.history() && key.Matches(msg, keys.HistoryNext):
+			f.browse(-1)
+			return f, nil
+		case key.Matches(msg, keys.NextField):
+			return f, f.setFocus(f.focus + 1)
+		case key.Matches(msg, keys.PrevField):
+			return f, f.setFocus(f.focus - 1)
+		}
+		if f.focus == fieldType {
+			switch {
+			case key.Matches(msg, keys.PrevType):
+				f.kind = (f.kind + len(searchTypes) - 1) % len(searchTypes)
+			case key.Matches(msg, keys.NextType):
+				f.kind = (f.kind + 1) % len(searchTypes)
+			}
+			return f, nil
+		}
+	}
+	var cmd tea.Cmd
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return f, cmd
+}
+
+func (f searchForm) View(t theme) string {
+	var b strings.Builder
+	for i := 0; i < fieldCount; i++ {
+		label := fmt.Sprintf("%-10s", fieldLabels[i])
+		if i == f.focus {
+			label = t.Focused.Render(label)
+		}
+		value := f.inputs[i].View()
+		if i == fieldType {
+			value = "< " + searchTypes[f.kind] + " >"
+			if i == f.focus {
+				value = t.Focused.Render(value)
+			}
+		}
+		b.WriteString(label + " " + value + "\n")
+	}
+	if len(f.recent) > 0 {
+		b.WriteString("\n" + t.Heading.Render("Recent searches") + "\n")
+		for i, filters := range f.recent[:min(len(f.recent), 5)] {
+			line := fmt.Sprintf("%s (%s)", filters.Query, filters.Type)
+			if i == f.browsed {
+				line = t.Focused.Render("› " + line)
+			} else {
+				line = t.Muted.Render("  " + line)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+)
+
+type keyMap struct {
+	Quit        key.Binding
+	Back        key.Binding
+	Submit      key.Binding
+	NextField   key.Binding
+	PrevField   key.Binding
+	HistoryPrev key.Binding
+	HistoryNext key.Binding
+	PrevType    key.Binding
+	NextType    key.Binding
+	Toggle      key.Binding
+	SelectAll   key.Binding
+	SelectNone  key.Binding
+	Invert      key.Binding
+	Review      key.Binding
+	NewSearch   key.Binding
+	Export      key.Binding
+	Logs        key.Binding
+	Trace       key.Binding
+}
+
+func defaultKeyMap() keyMap {
+	return keyMap{
+		Quit:        key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
+		Back:        key.NewBinding(key.WithKeys("esc", "backspace"), key.WithHelp("esc", "back")),
+		Submit:      key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "continue")),
+		NextField:   key.NewBinding(key.WithKeys("tab", "down"), key.WithHelp("tab", "next field")),
+		PrevField:   key.NewBinding(key.WithKeys("shift+tab", "up"), key.WithHelp("shift+tab", "previous field")),
+		HistoryPrev: key.NewBinding(key.WithKeys("up"), key.WithHelp("↑", "older search")),
+		HistoryNext: key.NewBinding(key.WithKeys("down"), key.WithHelp("↓", "newer search")),
+		PrevType:    key.NewBinding(key.WithKeys("left"), key.WithHelp("←", "previous type")),
+		NextType:    key.NewBinding(key.WithKeys("right", " "), key.WithHelp("→", "next type")),
+		Toggle:      key.NewBinding(key.WithKeys("enter", "x"), key.WithHelp("enter", "toggle")),
+		SelectAll:   key.NewBinding(key.WithKeys("a"), key.WithHelp("a", "all")),
+		SelectNone:  key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "none")),
+		Invert:      key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "invert")),
+		Review:      key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "review")),
+		NewSearch:   key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "new search")),
+		Export:      key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export")),
+		Logs:        key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "logs")),
+		Trace:       key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "trace")),
+	}
+}
+
+// bindings maps the action names used in the keymap file to their bindings.
+func (k *keyMap) bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":         &k.Quit,
+		"back":         &k.Back,
+		"submit":       &k.Submit,
+		"next_field":   &k.NextField,
+		"prev_field":   &k.PrevField,
+		"history_prev": &k.HistoryPrev,
+		"history_next": &k.HistoryNext,
+		"prev_type":    &k.PrevType,
+		"next_type":    &k.NextType,
+		"toggle":       &k.Toggle,
+		"select_all":   &k.SelectAll,
+		"select_none":  &k.SelectNone,
+		"invert":       &k.Invert,
+		"review":       &k.Review,
+		"new_search":   &k.NewSearch,
+		"export":       &k.Export,
+		"logs":         &k.Logs,
+		"trace":        &k.Trace,
+	}
+}
+
+func defaultKeyMapPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "GoFetcher", "keys.json")
+}
+
+// loadKeyMap applies overrides such as {"toggle": ["x"]} to the defaults.
+func loadKeyMap(path string) (keyMap, error) {
+	keys := defaultKeyMap()
+	if path == "" {
+		return keys, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return keys, err
+	}
+	var overrides map[string][]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return keys, fmt.Errorf("error parsing keymap %s: %w", path, err)
+	}
+	bindings := keys.bindings()
+	for action, values := range overrides {
+		binding, ok := bindings[action]
+		if !ok {
+			return keys, fmt.Errorf("unknown action %q in keymap %s", action, path)
+		}
+		if len(values) == 0 {
+			return keys, fmt.Errorf("no keys for action %q in keymap %s", action, path)
+		}
+		binding.SetKeys(values...)
+		binding.SetHelp(keyLabel(values[0]), binding.Help().Desc)
+	}
+	if err := keys.checkConflicts(); err != nil {
+		return keys, fmt.Errorf("%w in keymap %s", err, path)
+	}
+	return keys, nil
+}
+
+// keyScopes are the actions handled on one screen besides the global ones.
+// The history keys stand in for the field keys, so they get their own.
+var keyScopes = [][]string{
+	{"submit", "next_field", "prev_field", "prev_type", "next_type", "back"},
+	{"submit", "history_prev", "history_next", "back"},
+	{"toggle", "select_all", "select_none", "invert", "review", "back"},
+	{"new_search", "export", "back"},
+}
+
+var globalActions = []string{"quit", "logs", "trace"}
+
+// checkConflicts rejects a key bound to two actions of one scope.
+func (k *keyMap) checkConflicts() error {
+	bindings := k.bindings()
+	for _, scope := range keyScopes {
+		owner := map[string]string{}
+		for _, action := range append(globalActions[:len(globalActions):len(globalActions)], scope...) {
+			for _, name := range bindings[action].Keys() {
+				if other, ok := owner[name]; ok {
+					return fmt.Errorf("key %q is bound to both %q and %q", name, other, action)
+				}
+				owner[name] = action
+			}
+		}
+	}
+	return nil
+}
+
+func keyLabel(k string) string {
+	switch k {
+	case " ":
+		return "space"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	}
+	return k
+}
+
+// helpKeys adapts a set of bindings to help.KeyMap.
+type helpKeys []key.Binding
+
+func (h helpKeys) ShortHelp() []key.Binding {
+	return h
+}
+
+func (h helpKeys) FullHelp() [][]key.Binding {
+	return [][]key.Binding{h}
+}
+
+// helpFor returns the bindings that apply to state.
+func (m *model) helpFor(state State) helpKeys {
+	k := m.keys
+	// The trace panel is only there when tracing
+	k.Trace.SetEnabled(m.tracer != nil)
+	switch state {
+	case InputArtist:
+		if m.form.history() {
+			return helpKeys{k.Submit, k.NextField, k.HistoryPrev, k.HistoryNext, k.Back}
+		}
+		return helpKeys{k.Submit, k.NextField, k.PrevField, k.PrevType, k.NextType, k.Back}
+	case InputAuthorId, InputToken:
+		return helpKeys{k.Submit, k.Back, k.Logs, k.Trace, k.Quit}
+	case SelectReleases:
+		if m.list.FilterState() == list.Filtering {
+			return helpKeys{m.list.KeyMap.AcceptWhileFiltering, m.list.KeyMap.CancelWhileFiltering}
+		}
+		return helpKeys{k.Toggle, k.SelectAll, k.SelectNone, k.Invert, k.Review,
+			m.list.KeyMap.CursorUp, m.list.KeyMap.CursorDown, m.list.KeyMap.Filter, k.Back}
+	case ConfirmReleases:
+		return helpKeys{withHelpDesc(k.Submit, "start import"), k.Back}
+	case Done:
+		return helpKeys{k.NewSearch, k.Export, k.Logs, k.Trace, withHelpDesc(k.Back, "quit")}
+	}
+	return helpKeys{k.Quit}
+}
+
+func withHelpDesc(b key.Binding, desc string) key.Binding {
+	b.SetHelp(b.Help().Key, desc)
+	return b
+}
+
+// footer renders the help line for the current state.
+func (m *model) footer() string {
+	return m.help.View(m.helpFor(m.state))
+}
+
+// isEditKey reports whether k edits text rather than triggering a binding.
+func isEditKey(k string) bool {
+	return k == "backspace" || k == "ctrl+h" || len([]rune(k)) == 1
+}
+
+// typing reports whether a text field takes printable keys.
+func (m *model) typing() bool {
+	if m.showLogs || m.showTrace {
+		return false
+	}
+	switch m.state {
+	case InputArtist:
+		return m.form.focus != fieldType
+	case InputAuthorId, InputToken:
+		return true
+	case SelectReleases:
+		return m.list.FilterState() == list.Filtering
+	}
+	return false
+}
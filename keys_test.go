@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadKeyMap(t *testing.T) {
+	for _, test := range []struct {
+		name, keymap, err string
+	}{
+		{"defaults", `{}`, ""},
+		{"same key on other screens", `{"export": ["a"], "quit": ["q"]}`, ""},
+		{"unknown action", `{"jump": ["j"]}`, `unknown action "jump"`},
+		{"no keys", `{"toggle": []}`, `no keys for action "toggle"`},
+		{"conflict on one screen", `{"review": ["a"]}`, `key "a" is bound to both "select_all" and "review"`},
+		{"conflict with a global key", `{"quit": ["e"]}`, `key "e" is bound to both "quit" and "export"`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keys.json")
+			if err := os.WriteFile(path, []byte(test.keymap), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := loadKeyMap(path)
+			switch {
+			case test.err == "" && err != nil:
+				t.Fatalf("loadKeyMap() error = %v", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Fatalf("loadKeyMap() error = %v; want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// rotatingFile moves path to path.1, path.2... once it grows past maxBytes.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	f        *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxBytes int64, backups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path, maxBytes: maxBytes, backups: backups}
+	return r, r.open()
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.backups > 0 {
+		for i := r.backups - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// logRing keeps the last lines written to it for the log viewer.
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+}
+
+func newLogRing(max int) *logRing {
+	return &logRing{max: max}
+}
+
+func (r *logRing) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		r.lines = append(r.lines, line)
+	}
+	if over := len(r.lines) - r.max; over > 0 {
+		r.lines = append(r.lines[:0], r.lines[over:]...)
+	}
+	return len(p), nil
+}
+
+func (r *logRing) Last(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := max(len(r.lines)-n, 0)
+	return append([]string(nil), r.lines[start:]...)
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// logFlags adds the logging flags to fs; call the result once fs is parsed.
+func logFlags(fs *flag.FlagSet, defaultPath string, headless bool) func(extra ...io.Writer) error {
+	usage := "file to write logs to, rotated by size (empty logs to stderr)"
+	if !headless {
+		usage = "file to write logs to, rotated by size (empty keeps them in the log viewer only)"
+	}
+	path := fs.String("log-file", defaultPath, usage)
+	level := fs.String("log-level", "info", "minimum level logged: debug, info, warn or error")
+	maxSize := fs.Int64("log-max-size", 5<<20, "rotate the log file once it grows past this many bytes (0 never rotates)")
+	backups := fs.Int("log-backups", 3, "number of rotated log files to keep")
+	return func(extra ...io.Writer) error {
+		lvl, err := parseLogLevel(*level)
+		if err != nil {
+			return err
+		}
+		var out io.Writer = io.Discard
+		if headless {
+			out = os.Stderr
+		}
+		var openErr error
+		if *path != "" {
+			file, err := openRotatingFile(*path, *maxSize, *backups)
+			switch {
+			case err == nil:
+				out = file
+			case *path != defaultPath || explicitFlag(fs, "log-file"):
+				return err
+			default:
+				// A read-only state dir only costs the log file
+				openErr = err
+			}
+		}
+		out = io.MultiWriter(append([]io.Writer{out}, extra...)...)
+		l := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: lvl}))
+		slog.SetDefault(l)
+		services.SetLogger(l)
+		if openErr != nil {
+			slog.Warn("not writing a log file", "path", *path, "err", openErr)
+		}
+		return nil
+	}
+}
+
+func explicitFlag(fs *flag.FlagSet, name string) bool {
+	var set bool
+	fs.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+func defaultLogPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "gofetcher.log")
+}
+
+type logTickMsg struct{}
+
+// refreshLogs redraws the log viewer as lines arrive from other goroutines.
+func refreshLogs() tea.Cmd {
+	return tea.Tick(500*time.Millisecond, func(time.Time) tea.Msg {
+		return logTickMsg{}
+	})
+}
+
+func (m *model) logsView() string {
+	height := max(m.paneHeight-3, 1)
+	var lines []string
+	if m.logs != nil {
+		lines = m.logs.Last(height)
+	}
+	var b strings.Builder
+	b.WriteString(m.theme.Heading.Render("Logs") + "\n\n")
+	if len(lines) == 0 {
+		b.WriteString(m.theme.Muted.Render("Nothing logged yet") + "\n")
+	}
+	width := max(m.help.Width, 20)
+	for _, line := range lines {
+		style := m.theme.Muted
+		switch {
+		case strings.Contains(line, "level=ERROR"):
+			style = m.theme.Error
+		case strings.Contains(line, "level=WARN"):
+			style = m.theme.Warning
+		}
+		b.WriteString(style.Render(truncateLine(line, width)) + "\n")
+	}
+	return m.theme.Doc.Render(b.String() + m.help.View(helpKeys{withHelpDesc(m.keys.Logs, "close logs"), m.keys.Quit}))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "gofetcher.log")
+	r, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.f.Close()
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q; want %q", filepath.Base(name), data, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept more than 2 backups: %v", err)
+	}
+}
+
+func TestLogRing(t *testing.T) {
+	r := newLogRing(3)
+	r.Write([]byte("a\nb\n"))
+	r.Write([]byte("c\nd\n"))
+	if got, want := r.Last(10), []string{"b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Last(10) = %v; want %v", got, want)
+	}
+	if got, want := r.Last(1), []string{"d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Last(1) = %v; want %v", got, want)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	if level, err := parseLogLevel("warn"); err != nil || level.String() != "WARN" {
+		t.Errorf("parseLogLevel(warn) = %v, %v", level, err)
+	}
+	if _, err := parseLogLevel("loud"); err == nil || !strings.Contains(err.Error(), "loud") {
+		t.Errorf("parseLogLevel(loud) error = %v", err)
+	}
+}
+
+func TestLogFlagsUnwritableDefault(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	defer services.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	// A file where the state dir should be cannot hold the log
+	blocker := filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(blocker, "gofetcher.log")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	apply := logFlags(fs, path, false)
+	fs.Parse(nil)
+	ring := newLogRing(10)
+	if err := apply(ring); err != nil {
+		t.Fatalf("default log file: %v; want the log viewer only", err)
+	}
+	if lines := ring.Last(10); len(lines) != 1 || !strings.Contains(lines[0], "not writing a log file") {
+		t.Errorf("logged %q; want a warning", lines)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	apply = logFlags(fs, path, false)
+	fs.Parse([]string{"-log-file", path})
+	if err := apply(ring); err == nil {
+		t.Error("an explicit -log-file that cannot be opened was accepted")
+	}
+}
@@ -2,69 +2,197 @@ package main
 
 import (
 	"GoFetcher/services"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"io"
+	"github.com/muesli/termenv"
 	"log"
-	"net/http"
-	"strconv"
-	"time"
+	"log/slog"
+	"os"
+	"strings"
 )
 
-func forgeSearch(artist string) string {
-	return fmt.Sprintf(`https://api.discogs.com/database/search?q=%s&type=master&format=album&artist=%s&per_page=100&token=tgRatMaOmFfXjBwHNBlZDQtXrOAELZwpywEOCEbb`, artist, artist)
+// resolveDiscogsToken falls back to $DISCOGS_TOKEN, then the built-in token.
+func resolveDiscogsToken(token string) string {
+	if token != "" {
+		return token
+	}
+	if token := os.Getenv("DISCOGS_TOKEN"); token != "" {
+		return token
+	}
+	return "tgRatMaOmFfXjBwHNBlZDQtXrOAELZwpywEOCEbb"
 }
 
-func getRecords(url string) []services.Record {
-	resp, err := services.SendRequest(url)
-	if err != nil {
-		fmt.Println("Error:", err)
-		return nil
+// newFetcher builds the fetcher; only the file mode needs a cache directory.
+func newFetcher(mode services.CoverMode, cacheDir string, opts services.ImageOptions) (services.Fetcher, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	fetcher := &services.MasterFetcher{Options: opts}
+	if mode == services.CoverFile {
+		cache, err := services.NewImageCache(cacheDir, opts)
 		if err != nil {
-			fmt.Println("Error closing response body:", err)
+			return nil, err
 		}
-	}(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Error: Unexpected status code:", resp.StatusCode)
-		return nil
+		fetcher.Images = cache
 	}
+	return fetcher, nil
+}
 
-	data, err := services.DecodeJSON(resp)
-	if err != nil {
-		fmt.Println("Error:", err)
-		return nil
+// waitForUpload delivers the next message of importChoices, nil once done.
+func waitForUpload(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-events
+		if !ok {
+			return nil
+		}
+		return msg
 	}
-	return services.FilterMasterURLs(data)
 }
 
-func doStuff(masterUrls []services.Record, authorId uint, token string) {
-	for _, req := range services.FilterReleases(services.ProcessMasterURLs(masterUrls, authorId)) {
-		err := services.AddMusic(req, token)
-		if err != nil {
-			println("panic")
+func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"cache":  runCache,
+			"batch":  runBatch,
+			"upload": runUpload,
+		}
+		if run, ok := commands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
 		}
 	}
 
-}
+	var imageOpts services.ImageOptions
+	cacheDir := flag.String("cache-dir", services.DefaultCacheDir(), "directory used to cache downloaded cover images")
+	flag.IntVar(&imageOpts.MaxDimension, "image-max-size", 0, "scale cover images down to this many pixels on the longest side (0 keeps the original size)")
+	flag.StringVar(&imageOpts.Format, "image-format", "", "re-encode cover images as jpeg or png (empty keeps the original format)")
+	flag.IntVar(&imageOpts.Quality, "image-quality", 0, "jpeg quality used when re-encoding cover images")
+	flag.Int64Var(&imageOpts.MaxBytes, "image-max-bytes", 20<<20, "reject cover images larger than this many bytes (0 disables the limit)")
+	flag.BoolVar(&imageOpts.AllowMissing, "allow-missing-cover", false, "import records without a cover when the image cannot be downloaded")
+	discogsToken := flag.String("discogs-token", "", "Discogs personal access token (defaults to $DISCOGS_TOKEN)")
+	themeName := flag.String("theme", "auto", "colour theme: auto, "+themeNames()+"; NO_COLOR selects mono unless a theme is given here")
+	keymapPath := flag.String("keymap", defaultKeyMapPath(), "JSON file overriding key bindings, e.g. {\"toggle\": [\"x\"]}")
+	authorLookup := flag.String("author-lookup", "", "url of the media service user endpoint used to confirm author IDs, e.g. http://localhost:8080/users/%d")
+	lookupToken := flag.String("author-lookup-token", "", "media service token sent with the author lookup (defaults to $GOFETCHER_TOKEN)")
+	sessionFile := flag.String("session", sessionPath(), "file remembering recent searches between runs (empty disables it)")
+	settingsFile := flag.String("settings", settingsPath(), "file remembering the author ID, cover mode and author lookup between runs (empty disables it)")
+	coverMode := flag.String("cover-mode", string(services.CoverFile), "how covers are uploaded: file (cached on disk), stream (piped from Discogs) or url (link only)")
+	exportPath := flag.String("export", "releases.json", "file the Done screen exports imported records to; .csv, .ndjson or .json")
+	exportFormat := flag.String("export-format", "", "export format: json, ndjson or csv (defaults to the file extension)")
+	exportColumns := flag.String("export-columns", "", "comma separated columns to export (defaults to "+strings.Join(services.ExportColumns, ",")+")")
+	exportRaw := flag.Bool("export-raw", false, "include the raw Discogs master of each record in the export")
+	mediaUrl := flag.String("media-url", services.MediaServiceUrl, "media service endpoint records are uploaded to")
+	applyReplay := replayFlags(flag.CommandLine)
+	applyLog := logFlags(flag.CommandLine, defaultLogPath(), false)
+	applyTrace := traceFlags(flag.CommandLine)
+	flag.Parse()
 
-func main() {
+	logs := newLogRing(500)
+	if err := applyLog(logs); err != nil {
+		log.Fatal(err)
+	}
+	sess, err := loadSession(*sessionFile, *settingsFile)
+	if err != nil {
+		slog.Warn("ignoring session", "path", *sessionFile, "settings", *settingsFile, "err", err)
+	}
+	// Settings from the last run apply unless given on the command line
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if !explicit["cover-mode"] && sess.CoverMode != "" {
+		*coverMode = sess.CoverMode
+	}
+	if !explicit["author-lookup"] && sess.AuthorLookup != "" {
+		*authorLookup = sess.AuthorLookup
+	}
+
+	mode, err := services.ParseCoverMode(*coverMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := applyReplay(); err != nil {
+		log.Fatal(err)
+	}
+	tracer, writeTrace := applyTrace()
+	exportOpts, err := parseExportOptions(*exportFormat, *exportColumns, *exportRaw)
+	if err != nil {
+		log.Fatal(err)
+	}
+	keys, err := loadKeyMap(*keymapPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t, err := selectTheme(*themeName, explicit["theme"], os.Getenv, lipgloss.HasDarkBackground)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if t.Name == "mono" {
+		// Also drop the colours built into the list, help and spinner
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
 	m := initialModel()
+	m.setTheme(t)
+	m.keys = keys
+	m.logs = logs
+	m.tracer = tracer
+	m.searcher = services.NewDiscogs(resolveDiscogsToken(*discogsToken))
+	m.sink = &services.MediaService{Url: *mediaUrl, Mode: mode, Images: imageOpts}
+	m.authorLookup = *authorLookup
+	m.lookupToken = *lookupToken
+	if m.lookupToken == "" {
+		m.lookupToken = os.Getenv("GOFETCHER_TOKEN")
+	}
+	m.coverMode = mode
+	m.exportPath, m.exportOpts = *exportPath, exportOpts
+	m.session, m.sessionPath, m.settingsPath = sess, *sessionFile, *settingsFile
+	m.authorId = sess.AuthorId
+	m.form.recent = sess.RecentSearches
+	m.fetcher, err = newFetcher(mode, *cacheDir, imageOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		log.Fatal(err)
 	}
+	if err := writeTrace(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 type (
 	errMsg error
+
+	searchDoneMsg struct {
+		records []services.Record
+		err     error
+	}
+
+	authorMsg struct {
+		id   uint
+		name string
+		err  error
+	}
+
+	importDoneMsg struct {
+		results []services.ItemResult
+	}
+
+	uploadProgressMsg struct {
+		item        int
+		title       string
+		sent, total int64
+	}
 )
 
 type State int8
@@ -74,15 +202,64 @@ type model struct {
 	err      error
 	records  []services.Record
 	state    State
+	history  []State
 	list     list.Model
 	spinner  spinner.Model
 	choices  []services.Record
+	selected map[string]bool
 	authorId uint
 	token    string
-	artist   string
-}
+	form     searchForm
+	filters  services.SearchFilters
+
+	// searcher, fetcher and sink do the work of each step, so that they
+	// can be replaced by fakes
+	searcher services.Searcher
+	fetcher  services.Fetcher
+	sink     services.Sink
+
+	coverMode services.CoverMode
+	results   []services.ItemResult
+
+	exportPath string
+	exportOpts services.ExportOptions
+	exported   *exportDoneMsg
+
+	session      session
+	sessionPath  string
+	settingsPath string
+
+	inputErr       error
+	authorLookup   string
+	lookupToken    string
+	author         authorMsg
+	checkingAuthor bool
+
+	progress progress.Model
+	upload   uploadProgressMsg
+	uploads  chan tea.Msg
+
+	keys  keyMap
+	help  help.Model
+	theme theme
 
-var docStyle = lipgloss.NewStyle().Margin(1, 2)
+	details    map[string]*detailsMsg
+	paneWidth  int
+	paneHeight int
+
+	graphics    graphicsProtocol
+	covers      map[string]*cover
+	nextImageId uint32
+
+	logs     *logRing
+	showLogs bool
+
+	// tracer is nil unless started with -trace
+	tracer      *services.Tracer
+	showTrace   bool
+	traceCursor int
+	tracePinned bool
+}
 
 const (
 	InputArtist State = iota
@@ -92,6 +269,7 @@ const (
 	SelectArtist
 	Fetching
 	SelectReleases
+	ConfirmReleases
 	Done
 )
 
@@ -102,18 +280,32 @@ func initialModel() *model {
 	ti.Width = 20
 	s := spinner.New()
 	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
-	li := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	selected := make(map[string]bool)
+	li := list.New(nil, newCheckboxDelegate(selected), 0, 0)
+	// The footer shows our bindings, and q must not quit while selecting
+	li.SetShowHelp(false)
+	li.KeyMap.Quit.SetEnabled(false)
+	pr := progress.New(progress.WithDefaultGradient())
 
-	return &model{
-		ti:      ti,
-		err:     nil,
-		records: nil,
-		state:   InputArtist,
-		list:    li,
-		spinner: s,
+	m := &model{
+		ti:       ti,
+		form:     newSearchForm(),
+		err:      nil,
+		records:  nil,
+		selected: selected,
+		state:    InputArtist,
+		list:     li,
+		spinner:  s,
+		progress: pr,
+		details:  make(map[string]*detailsMsg),
+		keys:     defaultKeyMap(),
+		help:     help.New(),
+		graphics: detectGraphics(os.Getenv),
+		covers:   make(map[string]*cover),
 	}
+	m.setTheme(darkTheme())
+	return m
 }
 
 func (m *model) Init() tea.Cmd {
@@ -123,141 +315,373 @@ func (m *model) Init() tea.Cmd {
 
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
-	if m.state == Searching|Fetching {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	case errMsg:
+		m.err = msg
+		return m, nil
+	case searchDoneMsg:
+		m.records = msg.records
+		items := make([]list.Item, len(m.records))
+		for i, record := range m.records {
+			items[i] = record
+		}
+		clear(m.selected)
+		m.list.ResetFilter()
+		m.list.Select(0)
+		m.list.Title = selectionTitle(0, len(items))
+		m.enter(SelectReleases)
+		cmds := []tea.Cmd{m.list.SetItems(items), m.loadDetails()}
+		if msg.err != nil {
+			cmds = append(cmds, m.list.NewStatusMessage(m.theme.Error.Render(msg.err.Error())))
+		}
+		return m, tea.Batch(cmds...)
+	case authorMsg:
+		m.checkingAuthor = false
+		// Only a missing user blocks; an unreachable lookup is shown as a warning
+		if errors.Is(msg.err, services.ErrAuthorNotFound) {
+			m.inputErr = msg.err
+			return m, nil
+		}
+		m.author = msg
+		return m, nil
+	case importDoneMsg:
+		m.results = msg.results
+		m.exported = nil
+		m.enter(Done)
+		return m, nil
+	case exportDoneMsg:
+		m.exported = &msg
+		return m, nil
+	case detailsMsg:
+		m.details[msg.url] = &msg
+		return m, m.addCover(msg)
+	case sixelDrawMsg:
+		record, ok := m.list.SelectedItem().(services.Record)
+		c := m.covers[msg.url]
+		if m.state != SelectReleases || !ok || record.Url() != msg.url || c == nil {
+			return m, nil
+		}
+		cols, rows := m.coverCells()
+		row, col := m.sixelPosition()
+		return m, writeGraphics(encodeSixel(c.img, cols*cellWidth, rows*cellHeight), row, col)
+	case logTickMsg:
+		if m.showLogs || m.showTrace {
+			return m, refreshLogs()
+		}
 		return m, nil
+	case uploadProgressMsg:
+		m.upload = msg
+		return m, waitForUpload(m.uploads)
+	case tea.WindowSizeMsg:
+		h, v := m.theme.Doc.GetFrameSize()
+		m.paneWidth = (msg.Width - h) / 2
+		m.paneHeight = msg.Height - v
+		// Leave a line for the help footer below the list
+		m.list.SetSize(msg.Width-h-m.paneWidth, msg.Height-v-1)
+		m.help.Width = msg.Width - h
+		m.progress.Width = min(msg.Width-h, 80)
+		m.list, cmd = m.list.Update(msg)
+		return m, tea.Batch(cmd, m.transmitPending())
+	case list.FilterMatchesMsg:
+		m.list, cmd = m.list.Update(msg)
+		return m, tea.Batch(cmd, m.loadDetails(), m.drawSixel())
+	default:
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 	}
-	updateList := func(m *model) tea.Cmd {
-		return func() tea.Msg {
-			m.records = getRecords(forgeSearch(m.artist))
-			items := make([]list.Item, len(m.records))
-			for i, record := range m.records {
-				items[i] = record
-			}
-			m.list.SetItems(items)
-			m.list.Title = "Press Enter to select releases, Space to confirm selection"
-			m.state = SelectReleases
-			_, cmd := m.list.Update(msg)
-			return cmd
+}
+
+// remember records the search and returns the command saving it.
+func (m *model) remember() tea.Cmd {
+	m.session.addSearch(m.filters)
+	m.session.AuthorId = m.authorId
+	m.session.CoverMode = string(m.coverMode)
+	m.session.AuthorLookup = m.authorLookup
+	m.form.recent = m.session.RecentSearches
+	m.form.browsed = -1
+	sess, statePath, configPath := m.session, m.sessionPath, m.settingsPath
+	return func() tea.Msg {
+		if err := sess.save(statePath, configPath); err != nil {
+			slog.Warn("saving session failed", "path", statePath, "settings", configPath, "err", err)
 		}
+		return nil
+	}
+}
+
+// search runs the Discogs search for the current filters.
+func (m *model) search() tea.Cmd {
+	filters := m.filters
+	return func() tea.Msg {
+		records, err := m.searcher.Search(filters)
+		return searchDoneMsg{records: records, err: err}
 	}
-	fetchReleases := func(m *model) tea.Cmd {
-		return func() tea.Msg {
-			doStuff(m.choices, m.authorId, m.token)
-			m.state = Done
-			_, cmd := m.spinner.Update(spinner.TickMsg{
-				Time: time.Now(),
-			})
-			return cmd
+}
+
+// importChoices imports the selection, reporting progress on m.uploads.
+func (m *model) importChoices() tea.Cmd {
+	choices, authorId, token, uploads := m.choices, m.authorId, m.token, m.uploads
+	return func() tea.Msg {
+		defer close(uploads)
+		results := services.Import(choices, services.ImportOptions{
+			AuthorId: authorId,
+			Token:    token,
+			Fetcher:  m.fetcher,
+			Sink:     m.sink,
+			Progress: func(i int, title string, sent, total int64) {
+				// Drop updates rather than stall the upload when the TUI lags behind
+				select {
+				case uploads <- uploadProgressMsg{item: i, title: title, sent: sent, total: total}:
+				default:
+				}
+			},
+		})
+		return importDoneMsg{results: results}
+	}
+}
+
+func (m *model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	// Global keys that are also text are typed into a focused field instead
+	global := !m.typing() || !isEditKey(msg.String())
+	if global && key.Matches(msg, m.keys.Quit) {
+		return m, tea.Quit
+	}
+	// The log viewer and the trace panel cover every screen and take the
+	// keys while open
+	if (global && key.Matches(msg, m.keys.Logs)) || (m.showLogs && key.Matches(msg, m.keys.Back)) {
+		m.showLogs, m.showTrace = !m.showLogs, false
+		if m.showLogs {
+			return m, refreshLogs()
 		}
+		return m, nil
 	}
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
-			return m, tea.Quit
-		case tea.KeyEnter:
-			switch m.state {
-			case InputArtist:
-				m.state = InputAuthorId
-				m.artist = m.ti.Value()
-				m.ti.SetValue("")
-				return m, nil
-			case InputAuthorId:
-				m.state = InputToken
-				val, _ := strconv.Atoi(m.ti.Value())
-				m.authorId = uint(val)
-				m.ti.SetValue("")
-				return m, nil
-			case InputToken:
-				m.state = Searching
-				m.token = m.ti.Value()
-				m.ti.SetValue("")
-				return m, tea.Batch(m.spinner.Tick, updateList(m))
-
-			case SelectReleases:
-				item := m.list.Items()[m.list.Index()]
-				m.choices = append(m.choices, item.(services.Record))
-				m.list.RemoveItem(m.list.Index())
-				return m, nil
-			default:
+	if (global && m.tracer != nil && key.Matches(msg, m.keys.Trace)) || (m.showTrace && key.Matches(msg, m.keys.Back)) {
+		m.showTrace, m.showLogs = !m.showTrace, false
+		if m.showTrace {
+			m.tracePinned = false
+			return m, refreshLogs()
+		}
+		return m, nil
+	}
+	if m.showTrace {
+		m.traceKey(msg)
+		return m, nil
+	}
+	if m.showLogs {
+		return m, nil
+	}
+	// A back key that also edits text only goes back from an empty field
+	back := func(empty bool) bool {
+		return key.Matches(msg, m.keys.Back) && (empty || !isEditKey(msg.String()))
+	}
+
+	switch m.state {
+	case Searching, Fetching:
+		// Ignore input while work is in progress
+		return m, nil
+	case InputArtist:
+		switch {
+		case key.Matches(msg, m.keys.Submit):
+			m.filters = m.form.Filters()
+			if m.filters.Query == "" {
 				return m, nil
 			}
-		case tea.KeySpace:
-			switch m.state {
-			case InputArtist:
-				m.ti, cmd = m.ti.Update(msg)
-				return m, cmd
-			case SelectReleases:
-				m.state = Fetching
-				m.spinner, cmd = m.spinner.Update(msg)
-				return m, tea.Batch(cmd, fetchReleases(m))
-			default:
+			m.goTo(InputAuthorId)
+			return m, nil
+		case back(false):
+			// Nothing comes before the search form
+			return m, tea.Quit
+		}
+		m.form, cmd = m.form.Update(msg, m.keys)
+		return m, cmd
+	case InputAuthorId, InputToken:
+		switch {
+		case key.Matches(msg, m.keys.Submit) && m.state == InputAuthorId:
+			id, err := validateAuthorId(m.ti.Value())
+			m.inputErr = err
+			if err != nil {
 				return m, nil
 			}
-
-		default:
-			switch m.state {
-			case InputArtist, InputToken, InputAuthorId:
-				m.ti, cmd = m.ti.Update(msg)
-				return m, cmd
-			case SelectReleases:
-				m.list, cmd = m.list.Update(msg)
-				return m, cmd
-			default:
+			// Confirm the author first when a lookup is configured
+			if m.authorLookup != "" && m.author.id != id {
+				m.author = authorMsg{}
+				m.checkingAuthor = true
+				return m, tea.Batch(m.spinner.Tick, m.lookupAuthor(id))
+			}
+			m.authorId = id
+			m.goTo(InputToken)
+			return m, nil
+		case key.Matches(msg, m.keys.Submit):
+			m.inputErr = validateToken(m.ti.Value())
+			if m.inputErr != nil {
 				return m, nil
 			}
-
+			m.token = m.ti.Value()
+			m.ti.SetValue("")
+			save := m.remember()
+			m.goTo(Searching)
+			return m, tea.Batch(m.spinner.Tick, m.search(), save)
+		case back(m.ti.Value() == ""):
+			m.back()
+			return m, nil
 		}
-	case errMsg:
-		m.err = msg
-		return m, nil
-	case tea.WindowSizeMsg:
-		h, v := docStyle.GetFrameSize()
-		m.list.SetSize(msg.Width-h, msg.Height-v)
-		m.list, cmd = m.list.Update(msg)
-		return m, cmd
-	default:
-		m.spinner, cmd = m.spinner.Update(msg)
+		m.ti, cmd = m.ti.Update(msg)
+		m.validateInput()
 		return m, cmd
+	case SelectReleases:
+		if m.list.FilterState() == list.Filtering {
+			m.list, cmd = m.list.Update(msg)
+			return m, tea.Batch(cmd, m.loadDetails(), m.drawSixel())
+		}
+		switch {
+		case key.Matches(msg, m.keys.Toggle):
+			if item, ok := m.list.SelectedItem().(list.DefaultItem); ok {
+				m.toggle(selectionKey(item))
+			}
+		case key.Matches(msg, m.keys.SelectAll, m.keys.SelectNone, m.keys.Invert):
+			// Only the records left by the filter, so nothing hidden is imported
+			for _, item := range m.list.VisibleItems() {
+				k := selectionKey(item.(list.DefaultItem))
+				switch {
+				case key.Matches(msg, m.keys.SelectAll):
+					m.selected[k] = true
+				case key.Matches(msg, m.keys.SelectNone):
+					delete(m.selected, k)
+				default:
+					m.toggle(k)
+				}
+			}
+		case key.Matches(msg, m.keys.Review):
+			if m.filters.Type != "master" && m.filters.Type != "release" {
+				return m, m.list.NewStatusMessage("Only masters and releases can be imported")
+			}
+			// Collect the selection in list order for review
+			m.choices = nil
+			for _, item := range m.list.Items() {
+				if record, ok := item.(services.Record); ok && m.selected[selectionKey(record)] {
+					m.choices = append(m.choices, record)
+				}
+			}
+			if len(m.choices) == 0 {
+				return m, m.list.NewStatusMessage("Select at least one release first")
+			}
+			m.goTo(ConfirmReleases)
+			return m, nil
+		case key.Matches(msg, m.keys.Back):
+			if m.list.FilterState() == list.FilterApplied {
+				m.list.ResetFilter()
+			} else {
+				m.back()
+			}
+			return m, nil
+		default:
+			m.list, cmd = m.list.Update(msg)
+			return m, tea.Batch(cmd, m.loadDetails(), m.drawSixel())
+		}
+		m.list.Title = selectionTitle(len(m.selected), len(m.list.Items()))
+		return m, nil
+	case ConfirmReleases:
+		switch {
+		case key.Matches(msg, m.keys.Submit):
+			m.goTo(Fetching)
+			m.uploads = make(chan tea.Msg, 16)
+			return m, tea.Batch(m.spinner.Tick, m.importChoices(), waitForUpload(m.uploads))
+		case key.Matches(msg, m.keys.Back):
+			m.back()
+		}
+		return m, nil
+	case Done:
+		switch {
+		case key.Matches(msg, m.keys.NewSearch):
+			m.newSearch()
+		case key.Matches(msg, m.keys.Export):
+			return m, m.exportResults()
+		// A stray backspace must not drop results that were not exported
+		case key.Matches(msg, m.keys.Back) && !isEditKey(msg.String()):
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+	return m, nil
+}
+
+func (m *model) toggle(key string) {
+	if m.selected[key] {
+		delete(m.selected, key)
+	} else {
+		m.selected[key] = true
 	}
 }
 
 func (m *model) View() string {
+	if m.showLogs {
+		return m.logsView()
+	}
+	if m.showTrace {
+		return m.traceView()
+	}
 	switch m.state {
 	default:
 		return fmt.Sprintf(
-			"Type the name of the artist you want to search\n\n%s\n\n%s",
-			m.ti.View(),
-			"(esc to quit)",
+			"Search Discogs\n\n%s\n%s",
+			m.form.View(m.theme),
+			m.footer(),
 		) + "\n"
 	case InputArtist:
 		return fmt.Sprintf(
-			"Type the name of the artist you want to search\n\n%s\n\n%s",
-			m.ti.View(),
-			"(esc to quit)",
+			"Search Discogs\n\n%s\n%s",
+			m.form.View(m.theme),
+			m.footer(),
 		) + "\n"
 	case InputAuthorId:
 		return fmt.Sprintf(
-			"Type the id of the artist\n\n%s\n\n%s",
+			"Type the id of the artist\n\n%s\n%s\n%s",
 			m.ti.View(),
-			"(esc to quit)",
+			m.inputStatus(),
+			m.footer(),
 		) + "\n"
 	case InputToken:
 		return fmt.Sprintf(
-			"Type your token\n\n%s\n\n%s",
+			"Type your token\n\n%s\n%s\n%s",
 			m.ti.View(),
-			"(esc to quit)",
+			m.inputStatus(),
+			m.footer(),
 		) + "\n"
 	case Searching:
 		return fmt.Sprintf("\n\n   %s Searching...\n\n", m.spinner.View())
 	case SelectArtist:
-		return docStyle.Render(m.list.View())
+		return m.theme.Doc.Render(m.list.View())
 	case Fetching:
-		return fmt.Sprintf("\n\n   %s Fetching Releases...\n\n", m.spinner.View())
+		if m.upload.title == "" {
+			return fmt.Sprintf("\n\n   %s Fetching Releases...\n\n", m.spinner.View())
+		}
+		percent := 0.0
+		if m.upload.total > 0 {
+			percent = float64(m.upload.sent) / float64(m.upload.total)
+		}
+		return fmt.Sprintf("\n\n   %s Uploading %s (%d/%d, %d bytes)\n\n   %s\n\n",
+			m.spinner.View(), m.upload.title, m.upload.item+1, len(m.choices), m.upload.sent, m.progress.ViewAs(percent))
 	case SelectReleases:
-		return docStyle.Render(m.list.View())
+		return m.theme.Doc.Render(m.releasesView() + "\n" + m.footer())
+	case ConfirmReleases:
+		var b strings.Builder
+		for _, record := range m.choices {
+			b.WriteString("   • " + record.Title() + "\n")
+		}
+		return fmt.Sprintf("\n\n   Import %d releases?\n\n%s\n   %s",
+			len(m.choices), b.String(), m.footer())
 	case Done:
-		return fmt.Sprintf("\n\n   All done!\n\n%s", "   (ctrl+c to quit)")
+		var b strings.Builder
+		for _, result := range m.results {
+			style := m.theme.resultStyle(result.Err != nil, result.CoverErr != nil)
+			b.WriteString("   " + style.Render(result.String()) + "\n")
+		}
+		if status := m.exportStatus(); status != "" {
+			b.WriteString("\n   " + status + "\n")
+		}
+		return fmt.Sprintf("\n\n   All done!\n\n%s\n   %s", b.String(), m.footer())
 	}
 
 }
@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestResolveDiscogsToken(t *testing.T) {
+	t.Setenv("DISCOGS_TOKEN", "env-token")
+	if got := resolveDiscogsToken("flag-token"); got != "flag-token" {
+		t.Errorf("with -discogs-token: %q", got)
+	}
+	if got := resolveDiscogsToken(""); got != "env-token" {
+		t.Errorf("with $DISCOGS_TOKEN: %q", got)
+	}
+	t.Setenv("DISCOGS_TOKEN", "")
+	if got := resolveDiscogsToken(""); got == "" {
+		t.Error("no built-in token")
+	}
+}
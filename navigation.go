@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+)
+
+// goTo moves to state, remembering the current screen unless it is transient.
+func (m *model) goTo(state State) {
+	switch m.state {
+	case Searching, Fetching:
+	default:
+		m.history = append(m.history, m.state)
+	}
+	m.enter(state)
+}
+
+// back returns to the previous screen and reports whether there was one.
+func (m *model) back() bool {
+	if len(m.history) == 0 {
+		return false
+	}
+	state := m.history[len(m.history)-1]
+	m.history = m.history[:len(m.history)-1]
+	m.enter(state)
+	return true
+}
+
+// newSearch starts over, keeping the author ID and token.
+func (m *model) newSearch() {
+	m.history = nil
+	m.records = nil
+	m.choices = nil
+	m.results = nil
+	m.exported = nil
+	m.upload = uploadProgressMsg{}
+	clear(m.selected)
+	m.list.ResetFilter()
+	m.enter(InputArtist)
+}
+
+// enter switches to state and prefills its input.
+func (m *model) enter(state State) {
+	m.state = state
+	m.inputErr = nil
+	m.ti.EchoMode = echoMode(state)
+	m.ti.EchoCharacter = '•'
+	switch state {
+	case InputAuthorId:
+		m.ti.SetValue("")
+		if m.authorId != 0 {
+			m.ti.SetValue(fmt.Sprint(m.authorId))
+		}
+		m.ti.CursorEnd()
+	case InputToken:
+		m.ti.SetValue(m.token)
+		m.ti.CursorEnd()
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrAuthorNotFound = errors.New("author not found")
+
+// authorClient times out, since the author ID screen waits for it.
+var authorClient = &http.Client{Transport: &traceTransport{Next: http.DefaultTransport}, Timeout: 10 * time.Second}
+
+// LookupAuthor returns a name for user id; urlTemplate holds a %d for it.
+func LookupAuthor(urlTemplate string, id uint, token string) (string, error) {
+	url := urlTemplate
+	if strings.Contains(urlTemplate, "%d") {
+		url = fmt.Sprintf(urlTemplate, id)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	if token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	resp, err := authorClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("%w: no user with ID %d", ErrAuthorNotFound, id)
+	default:
+		return "", fmt.Errorf("unexpected status code %d looking up author %d", resp.StatusCode, id)
+	}
+
+	data, err := DecodeJSON(resp)
+	if err != nil {
+		return "", err
+	}
+	user, ok := data.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("unexpected author response for %d", id)
+	}
+	for _, field := range []string{"name", "username", "displayName", "email"} {
+		if name := stringField(user, field); name != "" {
+			return name, nil
+		}
+	}
+	return fmt.Sprintf("user %d", id), nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupAuthor(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.URL.Path != "/users/7" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 7, "username": "kim"}`))
+	}))
+	defer srv.Close()
+
+	name, err := LookupAuthor(srv.URL+"/users/%d", 7, "secret")
+	if err != nil || name != "kim" {
+		t.Errorf("LookupAuthor(7) = %q, %v; want kim", name, err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("authorization = %q; want the token", auth)
+	}
+
+	_, err = LookupAuthor(srv.URL+"/users/%d", 8, "secret")
+	if !errors.Is(err, ErrAuthorNotFound) {
+		t.Errorf("LookupAuthor(8) error = %v; want ErrAuthorNotFound", err)
+	}
+}
+
+func TestLookupAuthorTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	timeout := authorClient.Timeout
+	authorClient.Timeout = 50 * time.Millisecond
+	defer func() { authorClient.Timeout = timeout }()
+
+	_, err := LookupAuthor(srv.URL+"/users/%d", 7, "")
+	if err == nil || !strings.Contains(err.Error(), "Timeout") {
+		t.Errorf("LookupAuthor error = %v; want a timeout", err)
+	}
+}
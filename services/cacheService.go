@@ -0,0 +1,137 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const tempPrefix = ".tmp-"
+
+// ImageCache stores processed covers on disk, named after a hash of their url.
+type ImageCache struct {
+	Dir     string
+	Options ImageOptions
+}
+
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "GoFetcher", "images")
+	}
+	return filepath.Join(dir, "GoFetcher", "images")
+}
+
+func NewImageCache(dir string, opts ImageOptions) (*ImageCache, error) {
+	if dir == "" {
+		dir = DefaultCacheDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %w", err)
+	}
+	return &ImageCache{Dir: dir, Options: opts}, nil
+}
+
+// Key hashes the url together with the options, which change the stored bytes.
+func (c *ImageCache) Key(url string) string {
+	h := sha256.New()
+	h.Write([]byte(url))
+	if c.Options.MaxDimension != 0 || c.Options.Format != "" || c.Options.Quality != 0 {
+		fmt.Fprintf(h, "|%d|%s|%d", c.Options.MaxDimension, c.Options.Format, c.Options.Quality)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached image for url, or nil if it has not been stored yet.
+func (c *ImageCache) Get(url string) (*Image, error) {
+	matches, err := filepath.Glob(filepath.Join(c.Dir, c.Key(url)+".*"))
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	path := matches[0]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		// A corrupt entry is treated as a miss and overwritten later
+		return nil, nil
+	}
+	// Mark the entry as recently used so prune keeps it
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return &Image{
+		Path:        path,
+		Url:         url,
+		ContentType: "image/" + format,
+		Width:       config.Width,
+		Height:      config.Height,
+		Size:        len(data),
+		Data:        data,
+	}, nil
+}
+
+// Put writes img through a temporary file so readers never see half an image.
+func (c *ImageCache) Put(img *Image) error {
+	tmp, err := os.CreateTemp(c.Dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(img.Data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	path := filepath.Join(c.Dir, c.Key(img.Url)+ImageExtension(img.ContentType))
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	img.Path = path
+	return nil
+}
+
+// Prune removes images unused for maxAge (all for 0) and stale temporary files.
+func (c *ImageCache) Prune(maxAge time.Duration) (int, int64, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var removed int
+	var freed int64
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), tempPrefix) {
+			// Leave temporary files of a concurrent run alone
+			if info.ModTime().After(time.Now().Add(-time.Hour)) {
+				continue
+			}
+		} else if maxAge > 0 && info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, entry.Name())); err != nil {
+			return removed, freed, err
+		}
+		removed++
+		freed += info.Size()
+	}
+	return removed, freed, nil
+}
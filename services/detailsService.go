@@ -0,0 +1,122 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Details is what the preview pane shows for a record.
+type Details struct {
+	Year    string
+	Genres  []string
+	Styles  []string
+	Formats []string
+	Labels  []string
+	Tracks  int
+	Image   string
+	Thumb   *Image
+}
+
+// Details returns what is known about the record from the search alone.
+func (r Record) Details() Details {
+	return Details{
+		Year:    r.year,
+		Genres:  r.genres,
+		Styles:  r.styles,
+		Formats: r.formats,
+		Labels:  r.labels,
+		Tracks:  -1,
+		Image:   r.image,
+	}
+}
+
+// FetchDetails adds the master and thumbnail, leaving a failed thumbnail nil.
+func FetchDetails(record Record) (Details, error) {
+	details := record.Details()
+	resp, err := SendRequest(record.url)
+	if err != nil {
+		return details, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return details, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, record.url)
+	}
+	data, err := DecodeJSON(resp)
+	if err != nil {
+		return details, err
+	}
+	if master, ok := data.(map[string]any); ok {
+		if year := stringField(master, "year"); year != "" && year != "0" {
+			details.Year = year
+		}
+		if genres := stringsField(master, "genres"); len(genres) > 0 {
+			details.Genres = genres
+		}
+		if styles := stringsField(master, "styles"); len(styles) > 0 {
+			details.Styles = styles
+		}
+		if tracks, ok := master["tracklist"].([]any); ok {
+			details.Tracks = len(tracks)
+		}
+	}
+	details.Image = SelectImage(data, record.image)
+
+	thumb := record.thumb
+	if thumb == "" {
+		thumb = details.Image
+	}
+	details.Thumb, _ = fetchThumb(thumb)
+	return details, nil
+}
+
+func fetchThumb(url string) (*Image, error) {
+	if url == "" {
+		return nil, nil
+	}
+	resp, err := discogsClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if err != nil {
+		return nil, err
+	}
+	img, err := ProcessImage(data, ImageOptions{})
+	if err != nil {
+		return nil, err
+	}
+	img.Url = url
+	return img, nil
+}
+
+// stringField reads a string or number field of a decoded JSON object.
+func stringField(m map[string]any, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.Itoa(int(v))
+	}
+	return ""
+}
+
+// stringsField reads an array of strings from a decoded JSON object.
+func stringsField(m map[string]any, key string) []string {
+	values, ok := m[key].([]any)
+	if !ok {
+		return nil
+	}
+	var result []string
+	for _, value := range values {
+		if str, ok := interfaceToString(value); ok {
+			result = append(result, str)
+		}
+	}
+	return result
+}
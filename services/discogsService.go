@@ -0,0 +1,132 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const DiscogsBaseUrl = "https://api.discogs.com"
+
+// Discogs builds requests against the Discogs API.
+type Discogs struct {
+	BaseUrl string
+	Token   string
+}
+
+func NewDiscogs(token string) *Discogs {
+	return &Discogs{BaseUrl: DiscogsBaseUrl, Token: token}
+}
+
+// SearchFilters are the parameters of a database search; empty ones are left out.
+type SearchFilters struct {
+	Query    string
+	Type     string // master, release, artist or label
+	Format   string
+	YearFrom string
+	YearTo   string
+	Genre    string
+	Style    string
+	Country  string
+	Label    string
+	Page     int
+	PerPage  int
+}
+
+// releases reports whether the release filters apply, which they do not
+// to artist and label searches.
+func (f SearchFilters) releases() bool {
+	return f.Type == "master" || f.Type == "release"
+}
+
+// SearchUrl escapes every value, so names like "AC/DC" reach Discogs intact.
+func (d *Discogs) SearchUrl(filters SearchFilters) string {
+	query := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	set("q", filters.Query)
+	set("type", filters.Type)
+	// Masters and releases are matched on the artist as well as the query
+	if filters.releases() {
+		set("artist", filters.Query)
+		set("format", filters.Format)
+		set("genre", filters.Genre)
+		set("style", filters.Style)
+		set("country", filters.Country)
+		set("label", filters.Label)
+		// Discogs only matches a single year, ranges are filtered with FilterByYear
+		if filters.YearFrom != "" && filters.YearFrom == filters.YearTo {
+			set("year", filters.YearFrom)
+		}
+	}
+	perPage := filters.PerPage
+	if perPage == 0 {
+		perPage = 100
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+	if filters.Page > 1 {
+		query.Set("page", strconv.Itoa(filters.Page))
+	}
+	set("token", d.Token)
+
+	u, err := url.Parse(d.BaseUrl)
+	if err != nil {
+		u = &url.URL{Scheme: "https", Host: "api.discogs.com"}
+	}
+	u = u.JoinPath("database", "search")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// searchPages caps the pages a year range search reads.
+const searchPages = 10
+
+// Search returns the records of filters.Type within the year range. Discogs
+// only matches a single year, so a range reads every page unless Page is set.
+func (d *Discogs) Search(filters SearchFilters) ([]Record, error) {
+	ranged := filters.releases() && filters.YearFrom != filters.YearTo && filters.Page == 0
+	var records []Record
+	next := d.SearchUrl(filters)
+	for page := 0; next != "" && page < searchPages; page++ {
+		data, err := searchPage(next)
+		if err != nil {
+			return nil, err
+		}
+		results := FilterResults(data, filters.Type)
+		if filters.releases() {
+			results = FilterByYear(results, filters.YearFrom, filters.YearTo)
+		}
+		records = append(records, results...)
+		next = ""
+		if ranged {
+			next = nextPageUrl(data)
+		}
+	}
+	return records, nil
+}
+
+func searchPage(url string) (any, error) {
+	resp, err := SendRequest(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from Discogs: %d", resp.StatusCode)
+	}
+	return DecodeJSON(resp)
+}
+
+// nextPageUrl returns the url of the page after data, "" on the last one.
+func nextPageUrl(data any) string {
+	root, _ := data.(map[string]any)
+	pagination, _ := root["pagination"].(map[string]any)
+	urls, _ := pagination["urls"].(map[string]any)
+	next, _ := urls["next"].(string)
+	return next
+}
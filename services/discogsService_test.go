@@ -0,0 +1,206 @@
+package services
+
+import (
+	"GoFetcher/tests"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSearchUrlEncodesNames(t *testing.T) {
+	d := NewDiscogs("secret")
+	for _, tc := range []struct {
+		name    string
+		escaped string
+	}{
+		{"Sigur Rós", "Sigur+R%C3%B3s"},
+		{"AC/DC", "AC%2FDC"},
+		{"Simon & Garfunkel", "Simon+%26+Garfunkel"},
+		{"C# Orchestra", "C%23+Orchestra"},
+		{"100% Pure", "100%25+Pure"},
+		{"Sonic+Youth", "Sonic%2BYouth"},
+		{"Björk?", "Bj%C3%B6rk%3F"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := d.SearchUrl(SearchFilters{Query: tc.name, Type: "master"})
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("SearchUrl produced an invalid url %q: %v", raw, err)
+			}
+			if u.Fragment != "" {
+				t.Errorf("query leaked into the fragment: %q", raw)
+			}
+			query := u.Query()
+			if got := query.Get("q"); got != tc.name {
+				t.Errorf("q = %q; want %q", got, tc.name)
+			}
+			if got := query.Get("artist"); got != tc.name {
+				t.Errorf("artist = %q; want %q", got, tc.name)
+			}
+			if !strings.Contains(u.RawQuery, "q="+tc.escaped) {
+				t.Errorf("raw query %q does not contain q=%s", u.RawQuery, tc.escaped)
+			}
+			if got := query.Get("token"); got != "secret" {
+				t.Errorf("token = %q; want secret", got)
+			}
+		})
+	}
+}
+
+func TestSearchUrlFilters(t *testing.T) {
+	d := &Discogs{BaseUrl: "http://localhost:1234/api", Token: ""}
+	raw := d.SearchUrl(SearchFilters{
+		Query:    "Sonic Youth",
+		Type:     "release",
+		Format:   "Vinyl, LP",
+		YearFrom: "1990",
+		YearTo:   "1990",
+		Genre:    "Rock",
+		Style:    "Noise & Experimental",
+		Country:  "US",
+		Label:    "DGC",
+		Page:     2,
+	})
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Host != "localhost:1234" || u.Path != "/api/database/search" {
+		t.Errorf("unexpected endpoint %s", raw)
+	}
+	want := map[string]string{
+		"q":        "Sonic Youth",
+		"artist":   "Sonic Youth",
+		"type":     "release",
+		"format":   "Vinyl, LP",
+		"year":     "1990",
+		"genre":    "Rock",
+		"style":    "Noise & Experimental",
+		"country":  "US",
+		"label":    "DGC",
+		"page":     "2",
+		"per_page": "100",
+	}
+	query := u.Query()
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q; want %q", key, got, value)
+		}
+	}
+	if query.Has("token") {
+		t.Errorf("unexpected token in %s", raw)
+	}
+}
+
+func TestSearchUrlArtistIgnoresReleaseFilters(t *testing.T) {
+	for _, kind := range []string{"artist", "label"} {
+		raw := NewDiscogs("").SearchUrl(SearchFilters{
+			Query: "Sonic Youth", Type: kind, Format: "album", YearFrom: "1990", YearTo: "1990", Genre: "Rock", Label: "DGC",
+		})
+		u, _ := url.Parse(raw)
+		for _, key := range []string{"artist", "format", "year", "genre", "label"} {
+			if u.Query().Has(key) {
+				t.Errorf("%s search sends %s: %s", kind, key, raw)
+			}
+		}
+	}
+}
+
+func TestSearchUrlYearRange(t *testing.T) {
+	raw := NewDiscogs("").SearchUrl(SearchFilters{Query: "Goo", Type: "master", YearFrom: "1988", YearTo: "1992"})
+	u, _ := url.Parse(raw)
+	if u.Query().Has("year") {
+		t.Errorf("year ranges must not be sent as a single year: %s", raw)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	d := &Discogs{BaseUrl: srv.URL, Token: "secret"}
+
+	records, err := d.Search(SearchFilters{Query: "Sonic Youth", Type: "master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, record := range records {
+		titles = append(titles, record.Title())
+	}
+	if len(titles) != 3 || titles[0] != "Sonic Youth - Sonic Youth" {
+		t.Errorf("titles = %q", titles)
+	}
+
+	// Year ranges are filtered locally
+	records, err = d.Search(SearchFilters{Query: "Sonic Youth", Type: "master", YearFrom: "1985", YearTo: "1989"})
+	if err != nil || len(records) != 1 || records[0].Title() != "Sonic Youth - Daydream Nation" {
+		t.Errorf("got %d records, err %v; want Daydream Nation only", len(records), err)
+	}
+
+	// Records of another type are left out
+	records, err = d.Search(SearchFilters{Query: "Sonic Youth", Type: "artist"})
+	if err != nil || len(records) != 0 {
+		t.Errorf("got %d artist records, err %v; want none", len(records), err)
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	d := &Discogs{BaseUrl: srv.URL}
+
+	var titles []string
+	for page := 1; page <= 2; page++ {
+		records, err := d.Search(SearchFilters{Query: "Sonic Youth", Type: "master", Page: page, PerPage: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			titles = append(titles, record.Title())
+		}
+	}
+	want := []string{"Sonic Youth - Sonic Youth", "Sonic Youth - Daydream Nation", "Sonic Youth - Goo"}
+	if len(titles) != len(want) {
+		t.Fatalf("titles = %q; want %q", titles, want)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Errorf("titles[%d] = %q; want %q", i, titles[i], want[i])
+		}
+	}
+}
+
+func TestSearchYearRangeReadsEveryPage(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	d := &Discogs{BaseUrl: srv.URL}
+
+	records, err := d.Search(SearchFilters{Query: "Sonic Youth", Type: "master", YearFrom: "1985", YearTo: "1995", PerPage: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Title() != "Sonic Youth - Daydream Nation" || records[1].Title() != "Sonic Youth - Goo" {
+		t.Errorf("got %d records; want Daydream Nation and Goo", len(records))
+	}
+	if got := len(srv.Requests()); got != 3 {
+		t.Errorf("sent %d requests; want one per page", got)
+	}
+
+	// A single year is matched by Discogs in one request
+	records, err = d.Search(SearchFilters{Query: "Sonic Youth", Type: "master", YearFrom: "1990", YearTo: "1990", PerPage: 1})
+	if err != nil || len(records) != 1 || records[0].Title() != "Sonic Youth - Goo" {
+		t.Errorf("got %d records, err %v; want Goo only", len(records), err)
+	}
+	if got := len(srv.Requests()); got != 4 {
+		t.Errorf("sent %d requests in all; want 4", got)
+	}
+}
+
+func TestSearchServerError(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	d := &Discogs{BaseUrl: srv.URL}
+	srv.Fail("/database/search", http.StatusServiceUnavailable)
+
+	records, err := d.Search(SearchFilters{Query: "Sonic Youth", Type: "master"})
+	if err == nil || records != nil {
+		t.Fatalf("got %d records, err %v; want an error", len(records), err)
+	}
+}
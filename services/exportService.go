@@ -0,0 +1,390 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ExportFormat is the file format records are exported in.
+type ExportFormat string
+
+const (
+	ExportJSON   ExportFormat = "json"   // a single indented array
+	ExportNDJSON ExportFormat = "ndjson" // one object per line
+	ExportCSV    ExportFormat = "csv"    // a header row followed by one row per record
+)
+
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(s); format {
+	case ExportJSON, ExportNDJSON, ExportCSV:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (want json, ndjson or csv)", s)
+}
+
+// ExportFormatFor guesses the format from path, defaulting to JSON.
+func ExportFormatFor(path string) ExportFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return ExportNDJSON
+	case ".csv":
+		return ExportCSV
+	}
+	return ExportJSON
+}
+
+// ExportColumns are the exportable Request fields in default order.
+var ExportColumns = []string{"title", "genre", "additional", "description", "releaseDate", "imageUrl", "image", "authorId"}
+
+// RawColumn holds the Discogs master when ExportOptions.Raw is set.
+const RawColumn = "raw"
+
+type ExportOptions struct {
+	Format ExportFormat
+	// Columns lists the fields to write, defaulting to ExportColumns.
+	Columns []string
+	// Raw adds the Discogs payload of each record as RawColumn.
+	Raw bool
+}
+
+// ExportRecord is a mapped record together with the payload it came from.
+type ExportRecord struct {
+	Request Request
+	Raw     any
+}
+
+func (o ExportOptions) columns() ([]string, error) {
+	columns := o.Columns
+	if len(columns) == 0 {
+		columns = ExportColumns
+	}
+	for _, column := range columns {
+		if _, ok := requestField(Request{}, column); !ok {
+			return nil, fmt.Errorf("unknown export column %q (want %s)", column, strings.Join(ExportColumns, ", "))
+		}
+	}
+	if o.Raw {
+		columns = append(columns[:len(columns):len(columns)], RawColumn)
+	}
+	return columns, nil
+}
+
+// ParseColumns splits a comma separated list of column names.
+func ParseColumns(s string) []string {
+	var columns []string
+	for _, column := range strings.Split(s, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+func requestField(r Request, column string) (any, bool) {
+	switch column {
+	case "title":
+		return r.Title, true
+	case "genre":
+		return r.Genre, true
+	case "additional":
+		return r.Additional, true
+	case "description":
+		return r.Description, true
+	case "releaseDate":
+		return r.ReleaseDate, true
+	case "imageUrl":
+		return r.ImageUrl, true
+	case "image":
+		return r.Image, true
+	case "authorId":
+		return r.AuthorId, true
+	}
+	return nil, false
+}
+
+// exportObject encodes the exported columns in order.
+type exportObject struct {
+	columns []string
+	values  []any
+}
+
+// setRequestField is the inverse of requestField, ignoring unknown columns.
+func setRequestField(r *Request, column, value string) error {
+	switch column {
+	case "title":
+		r.Title = value
+	case "genre":
+		r.Genre = value
+	case "additional":
+		r.Additional = value
+	case "description":
+		r.Description = value
+	case "releaseDate":
+		// Keep a date already set from the year column
+		if value != "" {
+			r.ReleaseDate = value
+		}
+	case "year":
+		// Older dumps such as data.json only carry the year
+		if r.ReleaseDate != "" || value == "" {
+			return nil
+		}
+		year, err := strconv.Atoi(value)
+		if err != nil || year <= 0 {
+			return fmt.Errorf("invalid year %q", value)
+		}
+		r.ReleaseDate = fmt.Sprintf("%04d-01-01", year)
+	case "imageUrl":
+		r.ImageUrl = value
+	case "image":
+		r.Image = value
+	case "authorId":
+		if value == "" {
+			return nil
+		}
+		id, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid authorId %q", value)
+		}
+		r.AuthorId = uint(id)
+	}
+	return nil
+}
+
+func newExportObject(record ExportRecord, columns []string) exportObject {
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		if column == RawColumn {
+			values[i] = record.Raw
+			continue
+		}
+		values[i], _ = requestField(record.Request, column)
+	}
+	return exportObject{columns: columns, values: values}
+}
+
+func (o exportObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, column := range o.columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(column)
+		value, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Export writes records to w in the format and columns of opts.
+func Export(w io.Writer, records []ExportRecord, opts ExportOptions) error {
+	columns, err := opts.columns()
+	if err != nil {
+		return err
+	}
+	switch opts.Format {
+	case ExportNDJSON:
+		encoder := json.NewEncoder(w)
+		for _, record := range records {
+			if err := encoder.Encode(newExportObject(record, columns)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ExportCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+		row := make([]string, len(columns))
+		for _, record := range records {
+			for i, column := range columns {
+				if column == RawColumn {
+					raw, err := json.Marshal(record.Raw)
+					if err != nil {
+						return err
+					}
+					row[i] = string(raw)
+					continue
+				}
+				value, _ := requestField(record.Request, column)
+				row[i] = fmt.Sprint(value)
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		objects := make([]exportObject, len(records))
+		for i, record := range records {
+			objects[i] = newExportObject(record, columns)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(objects)
+	}
+}
+
+// ExportFile writes through a temporary file, guessing an empty format from path.
+func ExportFile(path string, records []ExportRecord, opts ExportOptions) error {
+	if opts.Format == "" {
+		opts.Format = ExportFormatFor(path)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".export-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	if err = Export(w, records, opts); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error exporting to %s: %w", path, err)
+	}
+	return nil
+}
+
+// ExportRecords collects the records that did not fail.
+func ExportRecords(results []ItemResult) []ExportRecord {
+	var records []ExportRecord
+	for _, result := range results {
+		if result.Request != nil && result.Err == nil {
+			records = append(records, ExportRecord{Request: *result.Request, Raw: result.Raw})
+		}
+	}
+	return records
+}
+
+// ReadExport reads records written by Export back; JSON may be an array or
+// a stream of objects, which covers NDJSON.
+func ReadExport(r io.Reader, format ExportFormat) ([]Request, error) {
+	var requests []Request
+	var err error
+	if format == ExportCSV {
+		requests, err = readExportCSV(r)
+	} else {
+		requests, err = readExportJSON(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, request := range requests {
+		if request.Title == "" {
+			return nil, fmt.Errorf("record %d has no title", i+1)
+		}
+	}
+	return requests, nil
+}
+
+func ReadExportFile(path string) ([]Request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	requests, err := ReadExport(bufio.NewReader(f), ExportFormatFor(path))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return requests, nil
+}
+
+func readExportCSV(r io.Reader) ([]Request, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	var requests []Request
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var request Request
+		for i, value := range row {
+			if i < len(header) {
+				if err := setRequestField(&request, strings.TrimSpace(header[i]), value); err != nil {
+					return nil, fmt.Errorf("record %d: %w", len(requests)+1, err)
+				}
+			}
+		}
+		requests = append(requests, request)
+	}
+}
+
+func readExportJSON(r io.Reader) ([]Request, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var objects []map[string]any
+	for {
+		var value any
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch value := value.(type) {
+		case []any:
+			for _, item := range value {
+				object, ok := item.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("record %d is not an object", len(objects)+1)
+				}
+				objects = append(objects, object)
+			}
+		case map[string]any:
+			objects = append(objects, value)
+		default:
+			return nil, fmt.Errorf("record %d is not an object", len(objects)+1)
+		}
+	}
+	requests := make([]Request, len(objects))
+	for i, object := range objects {
+		for column, value := range object {
+			switch value.(type) {
+			case string, json.Number:
+			default:
+				// Nested values such as the raw payload are not part of the request
+				continue
+			}
+			if err := setRequestField(&requests[i], column, fmt.Sprint(value)); err != nil {
+				return nil, fmt.Errorf("record %d: %w", i+1, err)
+			}
+		}
+	}
+	return requests, nil
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func exportRequests() []Request {
+	first := testRequest()
+	first.Image = "/cache/0a1b.png"
+	second := Request{
+		Title:       "Sonic Youth - Goo",
+		Additional:  "Dirty Boots, \"Tunic\"",
+		ReleaseDate: "1990-01-01",
+		AuthorId:    1000000,
+	}
+	return []Request{first, second}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	want := exportRequests()
+	records := make([]ExportRecord, len(want))
+	for i, request := range want {
+		records[i] = ExportRecord{Request: request, Raw: map[string]any{"id": i}}
+	}
+	for _, format := range []ExportFormat{ExportJSON, ExportNDJSON, ExportCSV} {
+		var b bytes.Buffer
+		if err := Export(&b, records, ExportOptions{Format: format, Raw: true}); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, err := ReadExport(&b, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read back %+v; want %+v", format, got, want)
+		}
+	}
+}
+
+func TestExportFileRoundTrip(t *testing.T) {
+	want := exportRequests()
+	records := []ExportRecord{{Request: want[0]}, {Request: want[1]}}
+	for _, name := range []string{"records.json", "records.ndjson", "records.csv"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := ExportFile(path, records, ExportOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadExportFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read back %+v; want %+v", name, got, want)
+		}
+	}
+}
+
+func TestReadExportYear(t *testing.T) {
+	inputs := map[ExportFormat]string{
+		ExportJSON:   `[{"title": "Goo", "year": 1990, "image": "/cache/goo.png"}]`,
+		ExportNDJSON: `{"title": "Goo", "year": "1990", "image": "/cache/goo.png"}` + "\n",
+		ExportCSV:    "title,year,releaseDate,image\nGoo,1990,,/cache/goo.png\n",
+	}
+	want := []Request{{Title: "Goo", ReleaseDate: "1990-01-01", Image: "/cache/goo.png"}}
+	for format, input := range inputs {
+		got, err := ReadExport(strings.NewReader(input), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v; want %+v", format, got, want)
+		}
+	}
+
+	// A full date wins over the year
+	got, err := ReadExport(strings.NewReader(`{"title": "Goo", "year": 1990, "releaseDate": "1990-06-25"}`), ExportJSON)
+	if err != nil || got[0].ReleaseDate != "1990-06-25" {
+		t.Errorf("releaseDate = %q, %v; want 1990-06-25", got[0].ReleaseDate, err)
+	}
+	if _, err := ReadExport(strings.NewReader("title,year\nGoo,ninety\n"), ExportCSV); err == nil {
+		t.Error("invalid year accepted")
+	}
+}
+
+func TestExportColumnsAndRaw(t *testing.T) {
+	records := []ExportRecord{{
+		Request: Request{Title: "Goo", ReleaseDate: "1990-01-01", AuthorId: 7},
+		Raw:     map[string]any{"id": 3, "title": "Goo"},
+	}}
+	opts := ExportOptions{Format: ExportCSV, Columns: ParseColumns(" authorId, title "), Raw: true}
+
+	var b bytes.Buffer
+	if err := Export(&b, records, opts); err != nil {
+		t.Fatal(err)
+	}
+	want := "authorId,title,raw\n7,Goo,\"{\"\"id\"\":3,\"\"title\"\":\"\"Goo\"\"}\"\n"
+	if b.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	opts.Format = ExportNDJSON
+	if err := Export(&b, records, opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"authorId":7,"title":"Goo","raw":{"id":3,"title":"Goo"}}` + "\n"; b.String() != want {
+		t.Errorf("ndjson = %s; want %s", b.String(), want)
+	}
+
+	opts.Columns = []string{"title", "year"}
+	if err := Export(&b, records, opts); err == nil || !strings.Contains(err.Error(), `"year"`) {
+		t.Errorf("unknown column: err = %v", err)
+	}
+}
+
+func TestExportRecordsSkipsFailures(t *testing.T) {
+	imported, failed := testRequest(), testRequest()
+	results := []ItemResult{
+		{Title: "imported", Request: &imported},
+		{Title: "upload failed", Request: &failed, Err: errors.New("media service returned 500")},
+		{Title: "master failed", Err: errors.New("unexpected status code 404")},
+	}
+	records := ExportRecords(results)
+	if len(records) != 1 || records[0].Request.Title != imported.Title {
+		t.Errorf("exported %+v; want only the imported record", records)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+type ImageOptions struct {
+	MaxDimension int    // longest side in pixels, 0 keeps the original size
+	Format       string // "jpeg", "png" or "" to keep the original format
+	Quality      int    // jpeg quality, 0 uses the encoder default
+	MaxBytes     int64  // largest download accepted, 0 means no limit
+	AllowMissing bool   // import records without a cover when the image is unusable
+}
+
+// Validate checks the options at startup rather than on every cover.
+func (o ImageOptions) Validate() error {
+	switch o.Format {
+	case "", "jpeg", "png":
+	default:
+		return fmt.Errorf("unknown image format %q (want jpeg or png)", o.Format)
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("image quality %d is not between 1 and 100", o.Quality)
+	}
+	return nil
+}
+
+var ErrInvalidImage = errors.New("invalid image")
+
+// ImageError describes why the cover at Url could not be used.
+type ImageError struct {
+	Url string
+	Err error
+}
+
+func (e *ImageError) Error() string {
+	return fmt.Sprintf("cover image %s: %v", e.Url, e.Err)
+}
+
+func (e *ImageError) Unwrap() error {
+	return e.Err
+}
+
+// MissingCoverError means AddMusic uploaded the record without its cover.
+type MissingCoverError struct {
+	Err error
+}
+
+func (e *MissingCoverError) Error() string {
+	return "uploaded without cover: " + e.Err.Error()
+}
+
+func (e *MissingCoverError) Unwrap() error {
+	return e.Err
+}
+
+// SplitCoverError tells a missing cover apart from a failed upload.
+func SplitCoverError(err error) (uploadErr, coverErr error) {
+	var missing *MissingCoverError
+	if errors.As(err, &missing) {
+		return nil, missing.Err
+	}
+	return err, nil
+}
+
+type Image struct {
+	Path        string
+	Url         string
+	ContentType string
+	Width       int
+	Height      int
+	Size        int
+	Data        []byte
+}
+
+func (i Image) String() string {
+	return fmt.Sprintf("%dx%d %s (%d bytes)", i.Width, i.Height, i.ContentType, i.Size)
+}
+
+// SelectImage picks the primary image, then a secondary one, then fallback.
+func SelectImage(master any, fallback string) string {
+	masterMap, ok := master.(map[string]any)
+	if !ok {
+		return fallback
+	}
+	images, ok := masterMap["images"].([]any)
+	if !ok {
+		return fallback
+	}
+	var secondary string
+	for _, img := range images {
+		imgMap, ok := img.(map[string]any)
+		if !ok {
+			continue
+		}
+		uri, _ := interfaceToString(imgMap["uri"])
+		if uri == "" {
+			continue
+		}
+		switch imgMap["type"] {
+		case "primary":
+			return uri
+		case "secondary":
+			if secondary == "" {
+				secondary = uri
+			}
+		}
+	}
+	if secondary != "" {
+		return secondary
+	}
+	return fallback
+}
+
+// ValidateImage checks the magic bytes of data against the formats we decode.
+func ValidateImage(data []byte) error {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")),
+		bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")),
+		bytes.HasPrefix(data, []byte("GIF87a")),
+		bytes.HasPrefix(data, []byte("GIF89a")):
+		return nil
+	}
+	return fmt.Errorf("%w: content looks like %s", ErrInvalidImage, http.DetectContentType(data))
+}
+
+// ProcessImage detects the format of data and scales or re-encodes it per opts.
+func ProcessImage(data []byte, opts ImageOptions) (*Image, error) {
+	if err := ValidateImage(data); err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	result := &Image{
+		ContentType: "image/" + format,
+		Width:       config.Width,
+		Height:      config.Height,
+		Size:        len(data),
+		Data:        data,
+	}
+
+	tooLarge := opts.MaxDimension > 0 && max(config.Width, config.Height) > opts.MaxDimension
+	convert := opts.Format != "" && opts.Format != format
+	if !tooLarge && !convert {
+		return result, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	if tooLarge {
+		img = resize(img, opts.MaxDimension)
+	}
+	target := format
+	if opts.Format != "" {
+		target = opts.Format
+	}
+
+	var buf bytes.Buffer
+	switch target {
+	case "jpeg":
+		var o *jpeg.Options
+		if opts.Quality > 0 {
+			o = &jpeg.Options{Quality: opts.Quality}
+		}
+		err = jpeg.Encode(&buf, img, o)
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("unsupported image format: %s", target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	result.ContentType = "image/" + target
+	result.Width = bounds.Dx()
+	result.Height = bounds.Dy()
+	result.Data = buf.Bytes()
+	result.Size = buf.Len()
+	return result, nil
+}
+
+// ImageExtension returns the file extension for an image content type.
+func ImageExtension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ".jpeg"
+	}
+}
+
+// resize scales img down to maxDim on its longest side with box averaging.
+func resize(img image.Image, maxDim int) image.Image {
+	src := img.Bounds()
+	w, h := src.Dx(), src.Dy()
+	if w >= h {
+		h = max(1, h*maxDim/w)
+		w = maxDim
+	} else {
+		w = max(1, w*maxDim/h)
+		h = maxDim
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := src.Min.Y + y*src.Dy()/h
+		y1 := max(y0+1, src.Min.Y+(y+1)*src.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := src.Min.X + x*src.Dx()/w
+			x1 := max(x0+1, src.Min.X+(x+1)*src.Dx()/w)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
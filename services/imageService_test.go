@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestSelectImage(t *testing.T) {
+	image := func(kind, uri string) map[string]any { return map[string]any{"type": kind, "uri": uri} }
+	tests := []struct {
+		name   string
+		master any
+		want   string
+	}{
+		{"primary", map[string]any{"images": []any{image("secondary", "s.jpg"), image("primary", "p.jpg")}}, "p.jpg"},
+		{"first secondary", map[string]any{"images": []any{image("secondary", "s1.jpg"), image("secondary", "s2.jpg")}}, "s1.jpg"},
+		{"empty uri skipped", map[string]any{"images": []any{image("primary", ""), image("secondary", "s.jpg")}}, "s.jpg"},
+		{"no images", map[string]any{}, "cover.jpg"},
+		{"not a master", "oops", "cover.jpg"},
+	}
+	for _, tt := range tests {
+		if got := SelectImage(tt.master, "cover.jpg"); got != tt.want {
+			t.Errorf("%s: got %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	data := encodePNG(t, 40, 20)
+
+	img, err := ProcessImage(data, ImageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Width != 40 || img.Height != 20 || !bytes.Equal(img.Data, data) {
+		t.Errorf("unchanged image = %v", img)
+	}
+
+	img, err = ProcessImage(data, ImageOptions{MaxDimension: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Width != 10 || img.Height != 5 {
+		t.Errorf("resized image = %v; want 10x5 png", img)
+	}
+
+	img, err = ProcessImage(data, ImageOptions{Format: "jpeg", Quality: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/jpeg" || img.Width != 40 || !bytes.HasPrefix(img.Data, []byte("\xff\xd8\xff")) || img.Size != len(img.Data) {
+		t.Errorf("re-encoded image = %v; want a 40x20 jpeg", img)
+	}
+
+	if _, err := ProcessImage([]byte("<html>"), ImageOptions{}); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("html: err = %v; want ErrInvalidImage", err)
+	}
+}
+
+func TestImageOptionsValidate(t *testing.T) {
+	for _, opts := range []ImageOptions{{}, {Format: "jpeg", Quality: 90}, {Format: "png"}} {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("%+v: %v", opts, err)
+		}
+	}
+	for _, opts := range []ImageOptions{{Format: "jpg"}, {Format: "webp"}, {Quality: 101}} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%+v accepted", opts)
+		}
+	}
+}
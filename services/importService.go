@@ -0,0 +1,91 @@
+package services
+
+// Searcher finds records matching a search, e.g. *Discogs.
+type Searcher interface {
+	Search(filters SearchFilters) ([]Record, error)
+}
+
+// Fetcher loads a record for import and preview, e.g. *MasterFetcher.
+type Fetcher interface {
+	// Fetch returns the master behind record and its cover. With a nil
+	// image and a non-nil error the record can still be imported without
+	// a cover.
+	Fetch(record Record) (any, *Image, error)
+	Details(record Record) (Details, error)
+}
+
+// ImageStore keeps covers between runs, e.g. *ImageCache.
+type ImageStore interface {
+	// Get returns the stored image for url, or nil when there is none.
+	Get(url string) (*Image, error)
+	// Put stores img and sets its Path.
+	Put(img *Image) error
+}
+
+// Sink receives the mapped records, e.g. *MediaService.
+type Sink interface {
+	Send(req Request, token string, progress func(sent, total int64)) error
+}
+
+type ImportOptions struct {
+	AuthorId uint
+	Token    string
+	Fetcher  Fetcher
+	// Sink receives the records; without one they are only fetched and
+	// mapped.
+	Sink Sink
+	// Progress is called while item i is sent to the sink.
+	Progress func(i int, title string, sent, total int64)
+}
+
+// Import fetches, maps and sends each record.
+func Import(records []Record, opts ImportOptions) []ItemResult {
+	var results []ItemResult
+	for i, record := range records {
+		result := ItemResult{Title: record.Title(), RequestId: newRequestId()}
+		log := logger.With("request_id", result.RequestId, "title", record.Title())
+		log.Info("importing record", "url", record.Url())
+		release, img, err := opts.Fetcher.Fetch(record)
+		if release == nil {
+			log.Error("fetching master failed", "err", err)
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			log.Warn("importing without a cover", "err", err)
+		}
+		if img != nil && img.Size > 0 {
+			log = log.With("cover_width", img.Width, "cover_height", img.Height, "cover_bytes", img.Size)
+		}
+		result.CoverErr = err
+		result.Raw = release
+		var progress func(sent, total int64)
+		if opts.Progress != nil {
+			progress = func(sent, total int64) {
+				opts.Progress(i, record.Title(), sent, total)
+			}
+		}
+		for _, req := range FilterReleases([]any{release}, []*Image{img}, opts.AuthorId) {
+			result.Request = &req
+			if opts.Sink != nil {
+				var coverErr error
+				result.Err, coverErr = SplitCoverError(opts.Sink.Send(req, opts.Token, progress))
+				if coverErr != nil {
+					log.Warn("imported without a cover", "err", coverErr)
+					result.CoverErr = coverErr
+				}
+			}
+		}
+		switch {
+		case result.Err != nil:
+			log.Error("sending record failed", "err", result.Err)
+		case opts.Sink == nil:
+			log.Info("record mapped without sending")
+		default:
+			log.Info("record imported")
+		}
+		results = append(results, result)
+	}
+	return results
+}
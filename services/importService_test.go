@@ -0,0 +1,127 @@
+package services
+
+import (
+	"GoFetcher/tests"
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestImportDryRun(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	records, err := (&Discogs{BaseUrl: srv.URL}).Search(SearchFilters{Query: "Sonic Youth", Type: "master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewImageCache(t.TempDir(), ImageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Fail("/masters/1", http.StatusNotFound)
+	var logs bytes.Buffer
+	SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer SetLogger(slog.New(discardHandler{}))
+
+	results := Import(records, ImportOptions{AuthorId: 5, Fetcher: &MasterFetcher{Images: cache}})
+	if len(results) != 3 {
+		t.Fatalf("got %d results; want 3", len(results))
+	}
+	if results[0].Err == nil || results[0].Request != nil {
+		t.Errorf("missing master: %+v", results[0])
+	}
+	for _, result := range results[1:] {
+		if result.Err != nil || result.Request == nil {
+			t.Fatalf("%s: %+v", result.Title, result)
+		}
+		if result.Request.AuthorId != 5 || result.Request.Image == "" {
+			t.Errorf("%s: request = %+v", result.Title, *result.Request)
+		}
+	}
+	if got := len(ExportRecords(results)); got != 2 {
+		t.Errorf("exported %d records; want 2", got)
+	}
+	// The processed cover is reported with each mapped record
+	mapped := 0
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "record mapped without sending") {
+			mapped++
+			if !strings.Contains(line, "cover_width=8 cover_height=8 cover_bytes=") {
+				t.Errorf("log line lacks the cover: %s", line)
+			}
+		}
+	}
+	if mapped != 2 {
+		t.Errorf("logged %d mapped records; want 2", mapped)
+	}
+}
+
+// fakeFetcher serves masters from memory, keyed by record url.
+type fakeFetcher map[string]map[string]any
+
+func (f fakeFetcher) Fetch(record Record) (any, *Image, error) {
+	master, ok := f[record.Url()]
+	if !ok {
+		return nil, nil, errors.New("not found")
+	}
+	return master, &Image{Url: "http://images.test/" + record.Title()}, nil
+}
+
+func (f fakeFetcher) Details(record Record) (Details, error) {
+	return record.Details(), nil
+}
+
+// fakeSink records what it is sent and fails titles listed in fail.
+type fakeSink struct {
+	sent []Request
+	fail map[string]bool
+}
+
+func (s *fakeSink) Send(req Request, token string, progress func(sent, total int64)) error {
+	if s.fail[req.Title] {
+		return errors.New("rejected")
+	}
+	if progress != nil {
+		progress(10, 10)
+	}
+	s.sent = append(s.sent, req)
+	return nil
+}
+
+func TestImportWithFakes(t *testing.T) {
+	records := []Record{{url: "a", title: "A"}, {url: "b", title: "B"}, {url: "c", title: "C"}}
+	fetcher := fakeFetcher{
+		"a": {"title": "A", "year": 1990.0},
+		"b": {"title": "B"},
+	}
+	sink := &fakeSink{fail: map[string]bool{"B": true}}
+	var progress []string
+
+	results := Import(records, ImportOptions{
+		AuthorId: 2,
+		Token:    "secret",
+		Fetcher:  fetcher,
+		Sink:     sink,
+		Progress: func(i int, title string, sent, total int64) {
+			progress = append(progress, title)
+		},
+	})
+	var lines []string
+	for _, result := range results {
+		lines = append(lines, result.String())
+	}
+	want := []string{"✓ A", "✗ B: rejected", "✗ C: not found"}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("result %d = %q; want %q", i, lines[i], want[i])
+		}
+	}
+	if len(sink.sent) != 1 || sink.sent[0].ImageUrl != "http://images.test/A" || sink.sent[0].ReleaseDate != "1990-01-01" {
+		t.Errorf("sink received %+v", sink.sent)
+	}
+	if len(progress) != 1 || progress[0] != "A" {
+		t.Errorf("progress = %q; want [A]", progress)
+	}
+}
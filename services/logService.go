@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// logger discards everything until SetLogger, so nothing is written over a TUI.
+var logger = slog.New(discardHandler{})
+
+// SetLogger sends the logs of the services package to l.
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// newRequestId ties together the log lines of one import item.
+func newRequestId() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
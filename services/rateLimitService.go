@@ -0,0 +1,59 @@
+package services
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimiter starts at most a fixed number of requests per minute.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func NewRateLimiter(perMinute int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &RateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the next request may be sent. A nil limiter never waits.
+func (l *RateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// discogsLimiter throttles every request sent to Discogs.
+var discogsLimiter *RateLimiter
+
+// limitedTransport waits for discogsLimiter before each request.
+type limitedTransport struct{}
+
+func (limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	discogsLimiter.Wait()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// discogsTransport traces requests before the rate limit or replay.
+var discogsTransport = &traceTransport{Next: limitedTransport{}}
+
+// discogsClient sends every request to Discogs.
+var discogsClient = &http.Client{Transport: discogsTransport}
+
+// SetRateLimit limits all requests to Discogs to perMinute, 0 for none.
+func SetRateLimit(perMinute int) {
+	discogsLimiter = NewRateLimiter(perMinute)
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ReplayMode records Discogs responses to, or serves them from, fixtures.
+type ReplayMode string
+
+const (
+	ReplayOff    ReplayMode = "off"    // talk to Discogs
+	ReplayRecord ReplayMode = "record" // talk to Discogs and store every response
+	ReplayServe  ReplayMode = "replay" // serve stored responses without touching the network
+)
+
+func ParseReplayMode(s string) (ReplayMode, error) {
+	switch mode := ReplayMode(s); mode {
+	case ReplayOff, ReplayRecord, ReplayServe:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown replay mode %q (want off, record or replay)", s)
+}
+
+// ErrNotRecorded is returned in replay mode for requests without a fixture.
+var ErrNotRecorded = errors.New("no recorded response")
+
+// fixture describes a recorded response, stored next to its body.
+type fixture struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+}
+
+// ReplayTransport records responses from Next into Dir or serves them, keyed
+// by method and url without the token.
+type ReplayTransport struct {
+	Dir  string
+	Mode ReplayMode
+	Next http.RoundTripper
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Mode == ReplayOff {
+		return t.Next.RoundTrip(req)
+	}
+	redacted := redactUrl(req.URL)
+	sum := sha256.Sum256([]byte(req.Method + " " + redacted))
+	base := filepath.Join(t.Dir, hex.EncodeToString(sum[:16]))
+	if t.Mode == ReplayServe {
+		return t.serve(req, base, redacted)
+	}
+
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	// Rate limited responses are not worth replaying
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return resp, nil
+	}
+	meta, err := json.MarshalIndent(fixture{
+		Method: req.Method,
+		Url:    redacted,
+		Status: resp.StatusCode,
+		Header: resp.Header,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(base+".body", body, 0644); err != nil {
+		return nil, fmt.Errorf("error recording %s: %w", redacted, err)
+	}
+	if err := os.WriteFile(base+".json", meta, 0644); err != nil {
+		return nil, fmt.Errorf("error recording %s: %w", redacted, err)
+	}
+	return resp, nil
+}
+
+func (t *ReplayTransport) serve(req *http.Request, base, redacted string) (*http.Response, error) {
+	data, err := os.ReadFile(base + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s %s in %s", ErrNotRecorded, req.Method, redacted, t.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta fixture
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("error reading fixture for %s: %w", redacted, err)
+	}
+	body, err := os.ReadFile(base + ".body")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", meta.Status, http.StatusText(meta.Status)),
+		StatusCode:    meta.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        meta.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// redactUrl drops the token and sorts the other parameters.
+func redactUrl(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	query.Del("token")
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+// SetReplay records to or replays from dir; replays skip the rate limit.
+func SetReplay(dir string, mode ReplayMode) error {
+	if mode == ReplayRecord {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	discogsTransport.Next = &ReplayTransport{Dir: dir, Mode: mode, Next: limitedTransport{}}
+	return nil
+}
@@ -1,23 +1,30 @@
 package services
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Record struct {
-	url   string
-	title string
-	image string
+	url     string
+	title   string
+	image   string
+	thumb   string
+	year    string
+	genres  []string
+	styles  []string
+	formats []string
+	labels  []string
 }
 
 type Request struct {
@@ -43,6 +50,10 @@ func (r Record) Description() string {
 	return r.url
 }
 
+func (r Record) Url() string {
+	return r.url
+}
+
 func SendRequest(url string) (*http.Response, error) {
 	// Create a new GET request
 	req, err := http.NewRequest("GET", url, nil)
@@ -50,14 +61,27 @@ func SendRequest(url string) (*http.Response, error) {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
-	// Perform the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error performing request: %w", err)
+	// Perform the request, waiting for the rate limit and retrying when
+	// Discogs asks us to slow down
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		resp, err := discogsClient.Do(req)
+		if err != nil {
+			logger.Warn("discogs request failed", "url", redactUrl(req.URL), "err", err)
+			return nil, fmt.Errorf("error performing request: %w", err)
+		}
+		logger.Debug("discogs request", "url", redactUrl(req.URL), "status", resp.StatusCode, "duration", time.Since(start))
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == 2 {
+			return resp, nil
+		}
+		resp.Body.Close()
+		delay := 10 * time.Second
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		}
+		logger.Warn("rate limited by Discogs", "url", redactUrl(req.URL), "retry_in", delay)
+		time.Sleep(delay)
 	}
-
-	return resp, nil
 }
 
 func DecodeJSON(resp *http.Response) (any, error) {
@@ -70,84 +94,219 @@ func DecodeJSON(resp *http.Response) (any, error) {
 	return data, nil
 }
 
-func FilterMasterURLs(data any) []Record {
-	var masterUrls []Record
+// FilterResults returns the search results of kind, e.g. master or release.
+func FilterResults(data any, kind string) []Record {
+	var records []Record
 
-	if releases, ok := data.(map[string]any)["results"]; ok {
-		for _, release := range releases.([]any) {
-			if releaseMap, ok := release.(map[string]any); ok {
-				if releaseMap["type"] == "master" {
-					masterUrls = append(masterUrls,
+	root, _ := data.(map[string]any)
+	if results, ok := root["results"].([]any); ok {
+		for _, result := range results {
+			if resultMap, ok := result.(map[string]any); ok {
+				if resultMap["type"] == kind {
+					records = append(records,
 						Record{
-							url:   releaseMap["resource_url"].(string),
-							title: releaseMap["title"].(string),
-							image: releaseMap["cover_image"].(string),
+							url:     stringField(resultMap, "resource_url"),
+							title:   stringField(resultMap, "title"),
+							image:   stringField(resultMap, "cover_image"),
+							thumb:   stringField(resultMap, "thumb"),
+							year:    stringField(resultMap, "year"),
+							genres:  stringsField(resultMap, "genre"),
+							styles:  stringsField(resultMap, "style"),
+							formats: stringsField(resultMap, "format"),
+							labels:  stringsField(resultMap, "label"),
 						})
 				}
 			}
 		}
 	}
 
-	return masterUrls
+	return records
+}
+
+// FilterByYear keeps records within the optional years, and those without one.
+func FilterByYear(records []Record, from, to string) []Record {
+	low, errLow := strconv.Atoi(from)
+	high, errHigh := strconv.Atoi(to)
+	if errLow != nil && errHigh != nil {
+		return records
+	}
+	var filtered []Record
+	for _, record := range records {
+		year, err := strconv.Atoi(record.year)
+		if err == nil && ((errLow == nil && year < low) || (errHigh == nil && year > high)) {
+			continue
+		}
+		filtered = append(filtered, record)
+	}
+	return filtered
 }
 
-func DownloadImage(url, filename string) (string, error) {
+// downloadImage fetches, validates and processes url through store.
+func downloadImage(url string, store ImageStore, opts ImageOptions) (*Image, error) {
+	if url == "" {
+		return nil, &ImageError{Url: url, Err: fmt.Errorf("%w: no image url", ErrInvalidImage)}
+	}
+	// Reuse the image if an earlier run already stored it
+	img, err := store.Get(url)
+	if err != nil || img != nil {
+		if img != nil {
+			logger.Debug("cover served from cache", "url", url, "path", img.Path)
+		}
+		return img, err
+	}
 	// Send an HTTP GET request to the Image URL
-	resp, err := http.Get(url)
+	resp, err := discogsClient.Get(url)
 	if err != nil {
-		return "", err
+		return nil, &ImageError{Url: url, Err: err}
 	}
 	defer resp.Body.Close()
-	// Create a new file to save the downloaded Image
-	file, err := os.Create("images/" + filename) // Save image in images directory
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ImageError{Url: url, Err: fmt.Errorf("unexpected status code %d", resp.StatusCode)}
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "image/") {
+		return nil, &ImageError{Url: url, Err: fmt.Errorf("%w: unexpected content type %s", ErrInvalidImage, ct)}
+	}
+	// Read one byte past the limit so oversized bodies can be detected
+	body := io.Reader(resp.Body)
+	if opts.MaxBytes > 0 {
+		if resp.ContentLength > opts.MaxBytes {
+			return nil, &ImageError{Url: url, Err: fmt.Errorf("image is %d bytes, limit is %d", resp.ContentLength, opts.MaxBytes)}
+		}
+		body = io.LimitReader(resp.Body, opts.MaxBytes+1)
+	}
+	data, err := io.ReadAll(body)
 	if err != nil {
-		return "", err
+		return nil, &ImageError{Url: url, Err: err}
 	}
-	// Copy the Image data from the HTTP response body to the file
-	_, err = io.Copy(file, resp.Body)
+	if opts.MaxBytes > 0 && int64(len(data)) > opts.MaxBytes {
+		return nil, &ImageError{Url: url, Err: fmt.Errorf("image exceeds limit of %d bytes", opts.MaxBytes)}
+	}
+	// Detect the real format and resize/convert as configured
+	img, err = ProcessImage(data, opts)
 	if err != nil {
-		file.Close()
-		return "", err
+		return nil, &ImageError{Url: url, Err: err}
 	}
-	// Close the file
-	err = file.Close()
+	img.Url = url
+	err = store.Put(img)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return file.Name(), nil
+	return img, nil
 }
-func sanitizeFilename(filename string) string {
-	re := regexp.MustCompile(`[\\/:*?"<>|]`)
-	return re.ReplaceAllString(filename, "_")
+
+// openCoverStream opens the cover at url with the checks of downloadImage.
+// Without a Content-Length, a body past opts.MaxBytes fails mid-upload.
+func openCoverStream(url string, opts ImageOptions) (io.ReadCloser, int64, string, error) {
+	resp, err := discogsClient.Get(url)
+	if err != nil {
+		return nil, 0, "", &ImageError{Url: url, Err: err}
+	}
+	fail := func(err error) (io.ReadCloser, int64, string, error) {
+		resp.Body.Close()
+		return nil, 0, "", &ImageError{Url: url, Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fail(fmt.Errorf("unexpected status code %d", resp.StatusCode))
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		return fail(fmt.Errorf("%w: unexpected content type %s", ErrInvalidImage, contentType))
+	}
+	if opts.MaxBytes > 0 && resp.ContentLength > opts.MaxBytes {
+		return fail(fmt.Errorf("image is %d bytes, limit is %d", resp.ContentLength, opts.MaxBytes))
+	}
+	body := bufio.NewReader(resp.Body)
+	head, err := body.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return fail(err)
+	}
+	if err := ValidateImage(head); err != nil {
+		return fail(err)
+	}
+	stream := &coverStream{Reader: body, Closer: resp.Body, url: url, limit: opts.MaxBytes}
+	return stream, resp.ContentLength, contentType, nil
 }
-func ProcessMasterURLs(masterUrls []Record, id uint) ([]any, []string, uint) {
-	var releases []any
-	var imagePaths []string
 
-	for _, record := range masterUrls {
-		resp, err := SendRequest(record.url)
-		if err != nil {
-			fmt.Println("Error:", err)
-			return nil, nil, id
-		}
-		data, err := DecodeJSON(resp)
-		if err != nil {
-			fmt.Println("Error:", err)
-			return nil, nil, id
-		}
-		releases = append(releases, data)
-		imagePath, err := DownloadImage(record.image, sanitizeFilename(record.title+".jpeg"))
-		if err != nil {
-			fmt.Println("Error:", err)
-			return nil, nil, id
+// coverStream fails once more than limit bytes have been read.
+type coverStream struct {
+	io.Reader
+	io.Closer
+	url   string
+	limit int64
+	read  int64
+}
+
+func (s *coverStream) Read(p []byte) (int, error) {
+	n, err := s.Reader.Read(p)
+	s.read += int64(n)
+	if s.limit > 0 && s.read > s.limit {
+		return n, &ImageError{Url: s.url, Err: fmt.Errorf("image exceeds limit of %d bytes", s.limit)}
+	}
+	return n, err
+}
+
+// MasterFetcher fetches masters and downloads covers into Images, if set.
+type MasterFetcher struct {
+	Images  ImageStore
+	Options ImageOptions
+}
+
+func (f *MasterFetcher) Fetch(record Record) (any, *Image, error) {
+	resp, err := SendRequest(record.url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, record.url)
+	}
+	data, err := DecodeJSON(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	url := SelectImage(data, record.image)
+	if f.Images == nil {
+		// The cover is streamed or linked at upload time instead of stored
+		return data, &Image{Url: url}, nil
+	}
+	img, err := downloadImage(url, f.Images, f.Options)
+	if err != nil {
+		var imageErr *ImageError
+		if f.Options.AllowMissing && errors.As(err, &imageErr) {
+			return data, nil, err
 		}
-		imagePaths = append(imagePaths, imagePath)
+		return nil, nil, err
 	}
+	return data, img, nil
+}
 
-	return releases, imagePaths, id
+func (f *MasterFetcher) Details(record Record) (Details, error) {
+	return FetchDetails(record)
 }
 
-func FilterReleases(releases []any, images []string, authorId uint) []Request {
+// ItemResult records what happened to one record during an import.
+type ItemResult struct {
+	Title    string
+	Err      error    // the record was not imported
+	CoverErr error    // the record was imported without a cover
+	Request  *Request // the mapped record, nil when the master could not be fetched
+	Raw      any      // the Discogs master the request was mapped from
+	// RequestId tags the log lines written while importing the record
+	RequestId string
+}
+
+func (r ItemResult) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("✗ %s: %v", r.Title, r.Err)
+	case r.CoverErr != nil:
+		return fmt.Sprintf("! %s (no cover: %v)", r.Title, r.CoverErr)
+	default:
+		return fmt.Sprintf("✓ %s", r.Title)
+	}
+}
+
+func FilterReleases(releases []any, images []*Image, authorId uint) []Request {
 	var filteredReleases []Request
 
 	for i, release := range releases {
@@ -161,21 +320,29 @@ func FilterReleases(releases []any, images []string, authorId uint) []Request {
 				ReleaseDate: "",
 				ImageUrl:    "placeHolder",
 				AuthorId:    authorId,
-				Image:       images[i],
+			}
+			if images[i] != nil {
+				filteredRelease.Image = images[i].Path
+				if images[i].Url != "" {
+					filteredRelease.ImageUrl = images[i].Url
+				}
 			}
 
 			// Add the desired fields to the filtered release map
-			filteredRelease.Title = releaseMap["title"].(string)
+			filteredRelease.Title = stringField(releaseMap, "title")
 			if year, ok := releaseMap["year"].(float64); ok {
 				filteredRelease.ReleaseDate = strconv.Itoa(int(year)) + "-01-01"
 			} else {
 				filteredRelease.ReleaseDate = "1900-01-01"
 			}
-			filteredRelease.Genre = releaseMap["genres"].([]any)[0].(string)
+			if genres := stringsField(releaseMap, "genres"); len(genres) > 0 {
+				filteredRelease.Genre = genres[0]
+			}
 			var trackInfo string
-			tracks := releaseMap["tracklist"].([]any)
+			tracks, _ := releaseMap["tracklist"].([]any)
 			for i, track := range tracks {
-				trackString, _ := interfaceToString(track.(map[string]any)["title"])
+				trackMap, _ := track.(map[string]any)
+				trackString, _ := interfaceToString(trackMap["title"])
 				trackInfo += trackString
 				if i < len(tracks)-1 {
 					trackInfo += "\n"
@@ -204,75 +371,93 @@ func interfaceToString(value any) (string, bool) {
 	return str, true // Return the string and true indicating successful conversion
 }
 
-func AddMusic(reqData Request, token string) error {
+// mediaClient sends uploads to the media service, traced.
+var mediaClient = &http.Client{Transport: &traceTransport{Next: http.DefaultTransport}}
 
-	url := "http://localhost:8080/medias"
-	method := "POST"
+func AddMusic(reqData Request, token string, opts UploadOptions) error {
 
-	payload := &bytes.Buffer{}
-	writer := multipart.NewWriter(payload)
+	url := opts.Url
+	if url == "" {
+		url = MediaServiceUrl
+	}
+	method := "POST"
 
-	// Add Image file to the request if available
-	if reqData.Image != "" {
+	// Add Image to the request if available, remembering its size so the
+	// Content-Length of the form can be computed up front
+	var image io.Reader
+	var imageName string
+	var missing error
+	imageSize := int64(0)
+	switch {
+	case opts.Mode == CoverStream && reqData.ImageUrl != "" && reqData.ImageUrl != "placeHolder":
+		// Check the image first so a bad cover fails before the upload starts
+		body, size, contentType, err := openCoverStream(reqData.ImageUrl, opts.Images)
+		if err != nil {
+			if !opts.Images.AllowMissing {
+				return err
+			}
+			missing = err
+			break
+		}
+		defer body.Close()
+		image = body
+		imageName = "cover" + ImageExtension(contentType)
+		imageSize = size
+	case opts.Mode != CoverUrl && reqData.Image != "":
 		// Open the image file
 		file, err := os.Open(reqData.Image)
 		if err != nil {
-			println(err)
 			return err
 		}
 		defer file.Close()
-
-		println(reqData.Image)
-		// Create a new form file part
-		part, err := writer.CreateFormFile("image", filepath.Base(reqData.Image))
+		info, err := file.Stat()
 		if err != nil {
-			println(err)
 			return err
 		}
+		image = file
+		imageName = filepath.Base(reqData.Image)
+		imageSize = info.Size()
+	}
 
-		// Copy the image data to the form file part
-		_, err = io.Copy(part, file)
+	// Stream the form through a pipe instead of buffering it in memory
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	length := int64(-1)
+	if imageSize >= 0 {
+		var err error
+		length, err = formLength(writer.Boundary(), reqData, image != nil, imageName, imageSize)
 		if err != nil {
-			println(err)
 			return err
 		}
 	}
+	body := &progressReader{r: pr, total: length, progress: opts.Progress}
 
-	// Add other form fields
-	_ = writer.WriteField("title", reqData.Title)
-	_ = writer.WriteField("genre", reqData.Genre)
-	_ = writer.WriteField("additional", reqData.Additional)
-	_ = writer.WriteField("description", reqData.Description)
-	_ = writer.WriteField("releaseDate", reqData.ReleaseDate)
-	_ = writer.WriteField("imageUrl", reqData.ImageUrl)
-	_ = writer.WriteField("average", "0")
-	_ = writer.WriteField("wants", "0")
-	_ = writer.WriteField("ratings", "0")
-	_ = writer.WriteField("doings", "0")
-	_ = writer.WriteField("type", "Music")
-	_ = writer.WriteField("authorId", fmt.Sprintf("%d", reqData.AuthorId))
-
-	err := writer.Close()
-	if err != nil {
-		return err
-	}
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, payload)
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return err
 	}
+	req.ContentLength = length
 	req.Header.Add("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	res, err := client.Do(req)
+	go func() {
+		err := writeMusicForm(writer, reqData, image, imageName)
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	start := time.Now()
+	res, err := mediaClient.Do(req)
 	if err != nil {
 		return err
 	}
+	logger.Debug("media service upload", "url", url, "title", reqData.Title, "mode", opts.Mode, "status", res.StatusCode, "bytes", length, "duration", time.Since(start))
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			log.Fatal()
+			logger.Warn("error closing media service response", "err", err)
 		}
 	}(res.Body)
 
@@ -291,20 +476,12 @@ func AddMusic(reqData Request, token string) error {
 	//	fmt.Println("Success!")
 	//}
 
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func WriteToFile(value any) {
-	prettyJSON, err := json.MarshalIndent(value, "", "    ")
-	if err != nil {
-		fmt.Println("Error formatting JSON:", err)
-		return
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("media service returned %d: %s", res.StatusCode, strings.TrimSpace(string(message)))
 	}
-	err = os.WriteFile("data.json", prettyJSON, 0644)
-	if err != nil {
-		fmt.Println(err)
+	if missing != nil {
+		return &MissingCoverError{Err: missing}
 	}
+	return nil
 }
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"GoFetcher/tests"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// searchRecords runs a master search against srv and returns the records.
+func searchRecords(t *testing.T, srv *tests.DiscogsServer, query string) []Record {
+	t.Helper()
+	d := &Discogs{BaseUrl: srv.URL}
+	resp, err := SendRequest(d.SearchUrl(SearchFilters{Query: query, Type: "master"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := DecodeJSON(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return FilterResults(data, "master")
+}
+
+func TestSearchFiltersResults(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	records := searchRecords(t, srv, "sonic youth")
+	if len(records) != 3 {
+		t.Fatalf("got %d records; want 3", len(records))
+	}
+	if got, want := records[1].Url(), srv.MasterUrl(2); got != want {
+		t.Errorf("url = %q; want %q", got, want)
+	}
+	details := records[1].Details()
+	if details.Year != "1988" || details.Labels[0] != "Enigma Records" {
+		t.Errorf("details = %+v", details)
+	}
+}
+
+func TestFilterResultsUnexpectedShapes(t *testing.T) {
+	for _, data := range []any{
+		nil,
+		"rate limited",
+		map[string]any{"results": "none"},
+		map[string]any{"results": []any{"a", 1, nil}},
+	} {
+		if records := FilterResults(data, "master"); len(records) != 0 {
+			t.Errorf("FilterResults(%v) = %d records; want none", data, len(records))
+		}
+	}
+}
+
+func TestMasterFetcherDownloadsCover(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	cache, err := NewImageCache(t.TempDir(), ImageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := searchRecords(t, srv, "daydream")[0]
+
+	fetcher := &MasterFetcher{Images: cache, Options: cache.Options}
+	master, img, err := fetcher.Fetch(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Width != 8 {
+		t.Errorf("image = %v", img)
+	}
+	if _, err := os.Stat(img.Path); err != nil {
+		t.Errorf("cover not cached: %v", err)
+	}
+
+	requests := FilterReleases([]any{master}, []*Image{img}, 7)
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests; want 1", len(requests))
+	}
+	want := Request{
+		Title:       "Sonic Youth - Daydream Nation",
+		Genre:       "Rock",
+		Additional:  "Teen Age Riot\nSilver Rocket\nThe Sprawl",
+		Description: "Double album.",
+		ReleaseDate: "1988-01-01",
+		ImageUrl:    srv.ImageUrl(2),
+		AuthorId:    7,
+		Image:       img.Path,
+	}
+	if requests[0] != want {
+		t.Errorf("request = %+v\nwant %+v", requests[0], want)
+	}
+
+	// The second import is served from the cache
+	before := len(srv.Requests())
+	if _, _, err := fetcher.Fetch(record); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(srv.Requests()) - before; got != 1 {
+		t.Errorf("second import sent %d requests; want only the master", got)
+	}
+}
+
+func TestMasterFetcherWithoutCache(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	record := searchRecords(t, srv, "goo")[0]
+
+	master, img, err := (&MasterFetcher{}).Fetch(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Goo has no images of its own, so the search result cover is used
+	if img.Url != srv.ImageUrl(3) || img.Path != "" {
+		t.Errorf("image = %+v", img)
+	}
+	request := FilterReleases([]any{master}, []*Image{img}, 1)[0]
+	if request.Description != "No description available." || request.Image != "" {
+		t.Errorf("request = %+v", request)
+	}
+}
+
+func TestMasterFetcherMissingCover(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	record := searchRecords(t, srv, "daydream")[0]
+
+	for _, allowMissing := range []bool{false, true} {
+		cache, err := NewImageCache(t.TempDir(), ImageOptions{AllowMissing: allowMissing})
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.Fail("/images/2.png", http.StatusNotFound)
+		fetcher := &MasterFetcher{Images: cache, Options: cache.Options}
+		master, img, err := fetcher.Fetch(record)
+		var imageErr *ImageError
+		if !errors.As(err, &imageErr) {
+			t.Fatalf("allowMissing=%v: err = %v; want an ImageError", allowMissing, err)
+		}
+		if img != nil || (master != nil) != allowMissing {
+			t.Errorf("allowMissing=%v: master = %v, image = %v", allowMissing, master != nil, img)
+		}
+		if allowMissing {
+			request := FilterReleases([]any{master}, []*Image{img}, 1)[0]
+			if request.ImageUrl != "placeHolder" || request.Image != "" {
+				t.Errorf("request = %+v", request)
+			}
+		}
+	}
+}
+
+func TestMasterFetcherServerError(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	record := searchRecords(t, srv, "goo")[0]
+	srv.Fail("/masters/3", http.StatusInternalServerError)
+
+	fetcher := &MasterFetcher{}
+	if _, _, err := fetcher.Fetch(record); err == nil {
+		t.Fatal("Fetch succeeded on a server error")
+	}
+	if _, _, err := fetcher.Fetch(record); err != nil {
+		t.Fatalf("Fetch failed after the server recovered: %v", err)
+	}
+}
+
+func TestDownloadImage(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	cache, err := NewImageCache(t.TempDir(), ImageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := downloadImage(srv.ImageUrl(2), cache, cache.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Url != srv.ImageUrl(2) || img.ContentType != "image/png" {
+		t.Errorf("image = %v", img)
+	}
+	before := len(srv.Requests())
+	if _, err := downloadImage(srv.ImageUrl(2), cache, cache.Options); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(srv.Requests()) - before; got != 0 {
+		t.Errorf("cached cover sent %d requests; want none", got)
+	}
+
+	for name, url := range map[string]string{"no url": "", "not an image": srv.MasterUrl(1)} {
+		_, err := downloadImage(url, cache, cache.Options)
+		var imageErr *ImageError
+		if !errors.As(err, &imageErr) {
+			t.Errorf("%s: err = %v; want an ImageError", name, err)
+		}
+	}
+	if _, err := downloadImage(srv.ImageUrl(3), cache, ImageOptions{MaxBytes: 10}); err == nil {
+		t.Error("downloadImage accepted a cover over MaxBytes")
+	}
+}
+
+func TestSendRequestRetriesWhenRateLimited(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	srv.Fail("/masters/1", http.StatusTooManyRequests, http.StatusTooManyRequests)
+
+	resp, err := SendRequest(srv.MasterUrl(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d; want 200 after retrying", resp.StatusCode)
+	}
+	if got := len(srv.Requests()); got != 3 {
+		t.Errorf("sent %d requests; want 3", got)
+	}
+}
+
+func TestSendRequestRateLimitHeaders(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	srv.SetRateLimit(2)
+
+	resp, err := SendRequest(srv.MasterUrl(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Discogs-Ratelimit-Remaining"); got != "1" {
+		t.Errorf("remaining = %q; want 1", got)
+	}
+
+	// The limit stays exhausted, so the retries give up with the 429
+	resp, err = SendRequest(srv.MasterUrl(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = SendRequest(srv.MasterUrl(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %d; want 429", resp.StatusCode)
+	}
+
+	srv.Reset()
+	resp, err = SendRequest(srv.MasterUrl(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d after reset; want 200", resp.StatusCode)
+	}
+}
+
+func TestFilterReleasesIncompleteMaster(t *testing.T) {
+	master := map[string]any{"title": "Untitled"}
+	requests := FilterReleases([]any{master, "not a master"}, []*Image{nil, nil}, 3)
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests; want 1", len(requests))
+	}
+	want := Request{
+		Title:       "Untitled",
+		Description: "No description available.",
+		ReleaseDate: "1900-01-01",
+		ImageUrl:    "placeHolder",
+		AuthorId:    3,
+	}
+	if requests[0] != want {
+		t.Errorf("request = %+v\nwant %+v", requests[0], want)
+	}
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var rateLimitHeaders = []string{"X-Discogs-Ratelimit", "X-Discogs-Ratelimit-Used", "X-Discogs-Ratelimit-Remaining", "Retry-After"}
+
+// redactedHeaders never leave the process in a trace.
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// TraceEntry is one request sent through a traced client.
+type TraceEntry struct {
+	Start          time.Time
+	Duration       time.Duration
+	Method         string
+	Url            string // with the token parameter removed
+	Status         int    // 0 when no response was received
+	Err            string
+	RequestHeader  http.Header
+	ResponseHeader http.Header
+	// RequestBody and ResponseBody hold at most the tracer's MaxBody bytes;
+	// the sizes count every byte sent or read by the caller.
+	RequestBody  string
+	RequestSize  int64
+	ResponseBody string
+	ResponseSize int64
+}
+
+// RateLimit returns the rate limit headers that were sent.
+func (e TraceEntry) RateLimit() []string {
+	var values []string
+	for _, name := range rateLimitHeaders {
+		if v := e.ResponseHeader.Get(name); v != "" {
+			values = append(values, name+": "+v)
+		}
+	}
+	return values
+}
+
+// Tracer keeps and logs the last requests sent to Discogs and the media service.
+type Tracer struct {
+	// MaxBody is the number of body bytes kept per request and response.
+	MaxBody int
+	// MaxEntries bounds the entries kept; older ones are dropped first.
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries []TraceEntry
+}
+
+func NewTracer(maxBody, maxEntries int) *Tracer {
+	return &Tracer{MaxBody: maxBody, MaxEntries: maxEntries}
+}
+
+// Entries returns the requests traced so far, oldest first.
+func (t *Tracer) Entries() []TraceEntry {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceEntry(nil), t.entries...)
+}
+
+func (t *Tracer) add(e TraceEntry) {
+	t.mu.Lock()
+	t.entries = append(t.entries, e)
+	if over := len(t.entries) - t.MaxEntries; t.MaxEntries > 0 && over > 0 {
+		t.entries = append(t.entries[:0], t.entries[over:]...)
+	}
+	t.mu.Unlock()
+
+	attrs := []any{"method", e.Method, "url", e.Url, "status", e.Status, "duration", e.Duration}
+	for _, name := range rateLimitHeaders {
+		if v := e.ResponseHeader.Get(name); v != "" {
+			attrs = append(attrs, strings.ToLower(name), v)
+		}
+	}
+	if e.RequestBody != "" {
+		attrs = append(attrs, "request_body", e.RequestBody)
+	}
+	attrs = append(attrs, "response_bytes", e.ResponseSize)
+	if e.ResponseBody != "" {
+		attrs = append(attrs, "response_body", e.ResponseBody)
+	}
+	if e.Err != "" {
+		attrs = append(attrs, "err", e.Err)
+	}
+	logger.Info("http trace", attrs...)
+}
+
+var tracer *Tracer
+
+// SetTracer traces requests to t, or stops tracing for nil.
+func SetTracer(t *Tracer) {
+	tracer = t
+}
+
+// traceTransport adds an entry once the response body is closed.
+type traceTransport struct {
+	Next http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr := tracer
+	if tr == nil {
+		return t.Next.RoundTrip(req)
+	}
+	entry := &TraceEntry{
+		Start:         time.Now(),
+		Method:        req.Method,
+		Url:           redactUrl(req.URL),
+		RequestHeader: redactHeader(req.Header),
+	}
+	var sent *captureBody
+	if req.Body != nil && req.Body != http.NoBody {
+		sent = &captureBody{ReadCloser: req.Body, max: tr.MaxBody}
+		if mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+			sent.form = &formCapture{delim: []byte("\r\n--" + params["boundary"]), max: tr.MaxBody, buf: []byte("\r\n")}
+		}
+		req = req.Clone(req.Context())
+		req.Body = sent
+	}
+	finish := func(received *captureBody) {
+		entry.Duration = time.Since(entry.Start)
+		if sent != nil {
+			entry.RequestBody, entry.RequestSize = sent.result(req.Header.Get("Content-Type"))
+		}
+		if received != nil {
+			entry.ResponseBody, entry.ResponseSize = received.result(entry.ResponseHeader.Get("Content-Type"))
+		}
+		tr.add(*entry)
+	}
+
+	resp, err := t.Next.RoundTrip(req)
+	if err != nil {
+		entry.Err = err.Error()
+		finish(nil)
+		return nil, err
+	}
+	entry.Status = resp.StatusCode
+	entry.ResponseHeader = redactHeader(resp.Header)
+	received := &captureBody{ReadCloser: resp.Body, max: tr.MaxBody, drain: isTextual(resp.Header.Get("Content-Type"))}
+	received.onClose = func() { finish(received) }
+	resp.Body = received
+	return resp, nil
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	for _, name := range redactedHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, "[redacted]")
+		}
+	}
+	return h
+}
+
+// captureBody passes a body through, keeping its first max bytes.
+type captureBody struct {
+	io.ReadCloser
+	max     int
+	onClose func()
+	// drain reads up to max bytes on Close when the caller has not, so
+	// responses that are closed unread still show in the trace.
+	drain bool
+	// form, for multipart bodies, keeps the form without its files.
+	form *formCapture
+
+	mu   sync.Mutex
+	head []byte
+	size int64
+	once sync.Once
+}
+
+func (c *captureBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.mu.Lock()
+	c.size += int64(n)
+	if c.form != nil {
+		c.form.write(p[:n])
+	} else if keep := min(c.max-len(c.head), n); keep > 0 {
+		c.head = append(c.head, p[:keep]...)
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *captureBody) Close() error {
+	if c.drain {
+		c.mu.Lock()
+		missing := c.max - len(c.head)
+		c.mu.Unlock()
+		if missing > 0 {
+			// One more byte tells whether the body was cut
+			_, _ = io.CopyN(io.Discard, c, int64(missing)+1)
+		}
+	}
+	err := c.ReadCloser.Close()
+	if c.onClose != nil {
+		c.once.Do(c.onClose)
+	}
+	return err
+}
+
+// result leaves out binary bodies, which would only garble the trace.
+func (c *captureBody) result(contentType string) (string, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.form != nil {
+		return c.form.text(), c.size
+	}
+	if !isTextual(contentType) {
+		return "", c.size
+	}
+	text := strings.ToValidUTF8(string(c.head), "�")
+	if c.size > int64(len(c.head)) {
+		text += "…"
+	}
+	return text, c.size
+}
+
+func isTextual(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "json") ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+// formCapture keeps a multipart form with each file replaced by its size.
+type formCapture struct {
+	delim []byte // CRLF and the boundary line ending each part
+	max   int
+	// buf holds what may still be the start of a delimiter or an unfinished
+	// part header. It starts with the CRLF the first boundary line lacks.
+	buf     []byte
+	header  bool // reading part headers rather than content
+	file    bool
+	skipped int64
+	out     []byte
+	cut     bool
+}
+
+func (f *formCapture) write(p []byte) {
+	f.buf = append(f.buf, p...)
+	for {
+		if f.header {
+			i := bytes.Index(f.buf, []byte("\r\n\r\n"))
+			if i < 0 {
+				return
+			}
+			f.emit(f.buf[:i+4])
+			f.file = bytes.Contains(f.buf[:i], []byte("filename="))
+			f.header, f.buf = false, f.buf[i+4:]
+			continue
+		}
+		i := bytes.Index(f.buf, f.delim)
+		if i < 0 {
+			keep := min(len(f.buf), len(f.delim)-1)
+			f.content(f.buf[:len(f.buf)-keep])
+			f.buf = append(f.buf[:0], f.buf[len(f.buf)-keep:]...)
+			return
+		}
+		f.content(f.buf[:i])
+		if f.file {
+			f.emit([]byte(fmt.Sprintf("[%d bytes]", f.skipped)))
+			f.file, f.skipped = false, 0
+		}
+		f.emit(f.delim)
+		f.header, f.buf = true, f.buf[i+len(f.delim):]
+	}
+}
+
+func (f *formCapture) content(b []byte) {
+	if f.file {
+		f.skipped += int64(len(b))
+	} else {
+		f.emit(b)
+	}
+}
+
+func (f *formCapture) emit(b []byte) {
+	n := min(f.max-len(f.out), len(b))
+	f.out = append(f.out, b[:max(n, 0)]...)
+	f.cut = f.cut || n < len(b)
+}
+
+func (f *formCapture) text() string {
+	text := string(f.out)
+	if f.cut {
+		text += "…"
+	} else if f.header {
+		// The closing boundary
+		text += string(f.buf)
+	}
+	return strings.TrimPrefix(strings.ToValidUTF8(text, "�"), "\r\n")
+}
+
+// WriteHAR writes the traced requests to w as HAR 1.2.
+func (t *Tracer) WriteHAR(w io.Writer) error {
+	type nameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	pairs := func(m map[string][]string) []nameValue {
+		list := []nameValue{}
+		for name, values := range m {
+			for _, v := range values {
+				list = append(list, nameValue{name, v})
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		return list
+	}
+	entries := []map[string]any{}
+	for _, e := range t.Entries() {
+		ms := float64(e.Duration) / float64(time.Millisecond)
+		var query url.Values
+		if u, err := url.Parse(e.Url); err == nil {
+			query = u.Query()
+		}
+		request := map[string]any{
+			"method":      e.Method,
+			"url":         e.Url,
+			"httpVersion": "HTTP/1.1",
+			"headers":     pairs(e.RequestHeader),
+			"queryString": pairs(query),
+			"cookies":     []nameValue{},
+			"headersSize": -1,
+			"bodySize":    e.RequestSize,
+		}
+		if e.RequestSize > 0 {
+			request["postData"] = map[string]any{"mimeType": e.RequestHeader.Get("Content-Type"), "text": e.RequestBody}
+		}
+		response := map[string]any{
+			"status":      e.Status,
+			"statusText":  http.StatusText(e.Status),
+			"httpVersion": "HTTP/1.1",
+			"headers":     pairs(e.ResponseHeader),
+			"cookies":     []nameValue{},
+			"content": map[string]any{
+				"size":     e.ResponseSize,
+				"mimeType": e.ResponseHeader.Get("Content-Type"),
+				"text":     e.ResponseBody,
+			},
+			"redirectURL": "",
+			"headersSize": -1,
+			"bodySize":    e.ResponseSize,
+		}
+		entry := map[string]any{
+			"startedDateTime": e.Start.Format(time.RFC3339Nano),
+			"time":            ms,
+			"request":         request,
+			"response":        response,
+			"cache":           map[string]any{},
+			"timings":         map[string]any{"send": 0, "wait": ms, "receive": 0},
+		}
+		if e.Err != "" {
+			entry["comment"] = e.Err
+		}
+		entries = append(entries, entry)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]any{"log": map[string]any{
+		"version": "1.2",
+		"creator": map[string]any{"name": "GoFetcher", "version": "1.0"},
+		"entries": entries,
+	}})
+}
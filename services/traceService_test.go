@@ -0,0 +1,169 @@
+package services
+
+import (
+	"GoFetcher/tests"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func startTrace(t *testing.T, maxBody int) *Tracer {
+	t.Helper()
+	tracer := NewTracer(maxBody, 0)
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	return tracer
+}
+
+func TestTraceDiscogsRequests(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	srv.SetRateLimit(10)
+	tracer := startTrace(t, 32)
+
+	d := &Discogs{BaseUrl: srv.URL, Token: "secret"}
+	resp, err := SendRequest(d.SearchUrl(SearchFilters{Query: "goo", Type: "master"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	cache, err := NewImageCache(t.TempDir(), ImageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := downloadImage(srv.ImageUrl(2), cache, cache.Options); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := tracer.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("traced %d requests; want 2", len(entries))
+	}
+	search, image := entries[0], entries[1]
+	if search.Method != "GET" || search.Status != 200 || strings.Contains(search.Url, "secret") {
+		t.Errorf("search traced as %s %s %d", search.Method, search.Url, search.Status)
+	}
+	if limits := strings.Join(search.RateLimit(), ", "); !strings.Contains(limits, "X-Discogs-Ratelimit: 10") {
+		t.Errorf("rate limit = %q", limits)
+	}
+	if !strings.HasSuffix(search.ResponseBody, "…") || len(search.ResponseBody) != 32+len("…") || search.ResponseSize <= 32 {
+		t.Errorf("response body %q of %d bytes; want the first 32 bytes", search.ResponseBody, search.ResponseSize)
+	}
+	if image.ResponseBody != "" || image.ResponseSize == 0 {
+		t.Errorf("image body %q of %d bytes; want only the size", image.ResponseBody, image.ResponseSize)
+	}
+}
+
+func TestTraceMediaUpload(t *testing.T) {
+	srv := tests.NewMediaServer(t, "secret")
+	tracer := startTrace(t, 4096)
+
+	if err := AddMusic(testRequest(), "secret", UploadOptions{Mode: CoverUrl, Url: srv.Url()}); err != nil {
+		t.Fatal(err)
+	}
+	entries := tracer.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("traced %d requests; want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Method != "POST" || e.Status != 201 {
+		t.Errorf("upload traced as %s %d", e.Method, e.Status)
+	}
+	if got := e.RequestHeader.Get("Authorization"); got != "[redacted]" {
+		t.Errorf("authorization header traced as %q", got)
+	}
+	if !strings.Contains(e.RequestBody, `name="title"`) || e.RequestSize != srv.Medias()[0].ContentLength {
+		t.Errorf("request body of %d bytes traced as %q", e.RequestSize, e.RequestBody)
+	}
+	if !strings.Contains(e.ResponseBody, `"id"`) {
+		t.Errorf("response body = %q", e.ResponseBody)
+	}
+}
+
+func TestTraceMediaUploadLeavesOutCover(t *testing.T) {
+	srv := tests.NewMediaServer(t, "secret")
+	tracer := startTrace(t, 4096)
+	cover := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 5000)...)
+	path := filepath.Join(t.TempDir(), "0a1b.png")
+	if err := os.WriteFile(path, cover, 0644); err != nil {
+		t.Fatal(err)
+	}
+	request := testRequest()
+	request.Image = path
+
+	if err := AddMusic(request, "secret", UploadOptions{Mode: CoverFile, Url: srv.Url()}); err != nil {
+		t.Fatal(err)
+	}
+	body := tracer.Entries()[0].RequestBody
+	if strings.Contains(body, "PNG") || !strings.Contains(body, `filename="0a1b.png"`) || !strings.Contains(body, "[5008 bytes]") {
+		t.Errorf("cover traced as %q", body)
+	}
+	// The fields after the cover are still there
+	if !strings.Contains(body, `name="authorId"`) || strings.HasSuffix(body, "…") {
+		t.Errorf("fields traced as %q", body)
+	}
+}
+
+func TestFormCaptureAcrossWrites(t *testing.T) {
+	form := "--b\r\nContent-Disposition: form-data; name=\"image\"; filename=\"a.png\"\r\n\r\n\x89PNG\r\n--\r\n" +
+		"--b\r\nContent-Disposition: form-data; name=\"title\"\r\n\r\nGoo\r\n--b--\r\n"
+	want := "--b\r\nContent-Disposition: form-data; name=\"image\"; filename=\"a.png\"\r\n\r\n[8 bytes]\r\n" +
+		"--b\r\nContent-Disposition: form-data; name=\"title\"\r\n\r\nGoo\r\n--b--\r\n"
+	for _, size := range []int{1, 3, len(form)} {
+		f := &formCapture{delim: []byte("\r\n--b"), max: 1000, buf: []byte("\r\n")}
+		for i := 0; i < len(form); i += size {
+			f.write([]byte(form[i:min(i+size, len(form))]))
+		}
+		if got := f.text(); got != want {
+			t.Errorf("written %d bytes at a time: %q; want %q", size, got, want)
+		}
+	}
+}
+
+func TestTraceWriteHAR(t *testing.T) {
+	srv := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	srv.Fail("/masters/2", 500)
+	tracer := startTrace(t, 1024)
+	resp, err := SendRequest(srv.MasterUrl(2) + "?token=secret&page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var b bytes.Buffer
+	if err := tracer.WriteHAR(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "secret") {
+		t.Errorf("HAR leaks the token:\n%s", b.String())
+	}
+	var har struct {
+		Log struct {
+			Version string
+			Entries []struct {
+				Request struct {
+					Method, Url string
+					QueryString []struct{ Name, Value string }
+				}
+				Response struct {
+					Status int
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("HAR = %+v", har.Log)
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.Method != "GET" || entry.Request.Url != srv.MasterUrl(2)+"?page=2" || entry.Response.Status != 500 {
+		t.Errorf("entry = %+v", entry)
+	}
+	if query := entry.Request.QueryString; len(query) != 1 || query[0].Name != "page" || query[0].Value != "2" {
+		t.Errorf("queryString = %+v; want only page=2", query)
+	}
+}
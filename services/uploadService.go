@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+// CoverMode selects how AddMusic hands the cover image to the media service.
+type CoverMode string
+
+const (
+	CoverFile   CoverMode = "file"   // upload the cached file from disk
+	CoverStream CoverMode = "stream" // pipe the image from Discogs into the upload
+	CoverUrl    CoverMode = "url"    // only send the Discogs image url
+)
+
+func ParseCoverMode(s string) (CoverMode, error) {
+	switch mode := CoverMode(s); mode {
+	case CoverFile, CoverStream, CoverUrl:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown cover mode %q (want file, stream or url)", s)
+}
+
+// MediaServiceUrl is the endpoint AddMusic posts to by default.
+const MediaServiceUrl = "http://localhost:8080/medias"
+
+type UploadOptions struct {
+	Mode CoverMode
+	// Url of the media endpoint, defaulting to MediaServiceUrl.
+	Url string
+	// Images limits streamed covers; with AllowMissing a bad one is left out.
+	Images ImageOptions
+	// Progress is called as the form is sent; total is -1 when the size of
+	// the cover is not known in advance.
+	Progress func(sent, total int64)
+}
+
+// MediaService uploads records to the media service with AddMusic.
+type MediaService struct {
+	Url    string
+	Mode   CoverMode
+	Images ImageOptions
+}
+
+func (s *MediaService) Send(req Request, token string, progress func(sent, total int64)) error {
+	return AddMusic(req, token, UploadOptions{Mode: s.Mode, Url: s.Url, Images: s.Images, Progress: progress})
+}
+
+// writeMusicForm writes reqData and the optional image; the caller closes writer.
+func writeMusicForm(writer *multipart.Writer, reqData Request, image io.Reader, imageName string) error {
+	if image != nil {
+		// Create a new form file part
+		part, err := writer.CreateFormFile("image", imageName)
+		if err != nil {
+			return err
+		}
+
+		// Copy the image data to the form file part
+		_, err = io.Copy(part, image)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Add other form fields
+	fields := [][2]string{
+		{"title", reqData.Title},
+		{"genre", reqData.Genre},
+		{"additional", reqData.Additional},
+		{"description", reqData.Description},
+		{"releaseDate", reqData.ReleaseDate},
+		{"imageUrl", reqData.ImageUrl},
+		{"average", "0"},
+		{"wants", "0"},
+		{"ratings", "0"},
+		{"doings", "0"},
+		{"type", "Music"},
+		{"authorId", fmt.Sprintf("%d", reqData.AuthorId)},
+	}
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formLength sizes the form by writing it with an empty image.
+func formLength(boundary string, reqData Request, hasImage bool, imageName string, imageSize int64) (int64, error) {
+	var counter countingWriter
+	writer := multipart.NewWriter(&counter)
+	if err := writer.SetBoundary(boundary); err != nil {
+		return 0, err
+	}
+	var image io.Reader
+	if hasImage {
+		image = strings.NewReader("")
+	}
+	if err := writeMusicForm(writer, reqData, image, imageName); err != nil {
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+	return int64(counter) + imageSize, nil
+}
+
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.sent += int64(n)
+	if p.progress != nil && n > 0 {
+		p.progress(p.sent, p.total)
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	if c, ok := p.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"GoFetcher/tests"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testRequest() Request {
+	return Request{
+		Title:       "Sonic Youth - Daydream Nation",
+		Genre:       "Rock",
+		Additional:  "Teen Age Riot\nSilver Rocket",
+		Description: "Double album & more",
+		ReleaseDate: "1988-01-01",
+		ImageUrl:    "http://discogs.example/images/2.png",
+		AuthorId:    7,
+	}
+}
+
+// wantFields are the form fields AddMusic must send for r.
+func wantFields(r Request) map[string]string {
+	return map[string]string{
+		"title":       r.Title,
+		"genre":       r.Genre,
+		"additional":  r.Additional,
+		"description": r.Description,
+		"releaseDate": r.ReleaseDate,
+		"imageUrl":    r.ImageUrl,
+		"average":     "0",
+		"wants":       "0",
+		"ratings":     "0",
+		"doings":      "0",
+		"type":        "Music",
+		"authorId":    "7",
+	}
+}
+
+func checkFields(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("field %s = %q; want %q", k, got[k], v)
+		}
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("unexpected field %s = %q", k, got[k])
+		}
+	}
+}
+
+func TestAddMusicFileMode(t *testing.T) {
+	srv := tests.NewMediaServer(t, "secret")
+	cover := []byte("\x89PNG\r\n\x1a\nnot really a png")
+	path := filepath.Join(t.TempDir(), "0a1b.png")
+	if err := os.WriteFile(path, cover, 0644); err != nil {
+		t.Fatal(err)
+	}
+	request := testRequest()
+	request.Image = path
+
+	var sent, total int64
+	err := AddMusic(request, "secret", UploadOptions{
+		Mode:     CoverFile,
+		Url:      srv.Url(),
+		Progress: func(s, t int64) { sent, total = s, t },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	medias := srv.Medias()
+	if len(medias) != 1 {
+		t.Fatalf("server received %d medias; want 1", len(medias))
+	}
+	media := medias[0]
+	checkFields(t, media.Fields, wantFields(request))
+	if !bytes.Equal(media.Image, cover) || media.ImageName != "0a1b.png" {
+		t.Errorf("image = %q named %q; want the cover file", media.Image, media.ImageName)
+	}
+	if media.ContentLength <= 0 || sent != media.ContentLength || total != media.ContentLength {
+		t.Errorf("content length %d, progress %d/%d; want them equal", media.ContentLength, sent, total)
+	}
+}
+
+func TestAddMusicStreamMode(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	srv := tests.NewMediaServer(t, "secret")
+	request := testRequest()
+	request.ImageUrl = discogs.ImageUrl(2)
+
+	if err := AddMusic(request, "secret", UploadOptions{Mode: CoverStream, Url: srv.Url()}); err != nil {
+		t.Fatal(err)
+	}
+	media := srv.Medias()[0]
+	checkFields(t, media.Fields, wantFields(request))
+	if !bytes.HasPrefix(media.Image, []byte("\x89PNG")) || media.ImageName != "cover.png" {
+		t.Errorf("image named %q does not hold the Discogs cover", media.ImageName)
+	}
+
+	// A missing cover fails before anything is uploaded
+	discogs.Fail("/images/2.png", http.StatusNotFound)
+	err := AddMusic(request, "secret", UploadOptions{Mode: CoverStream, Url: srv.Url()})
+	var imageErr *ImageError
+	if !errors.As(err, &imageErr) {
+		t.Errorf("err = %v; want an ImageError", err)
+	}
+	if got := len(srv.Medias()); got != 1 {
+		t.Errorf("server received %d medias; want 1", got)
+	}
+}
+
+func TestAddMusicStreamModeRejectsBadCovers(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n" + strings.Repeat("x", 100))
+	covers := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/error.png":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>Not here</html>"))
+		case "/mislabelled.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("<html>Not here</html>"))
+		case "/large.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		case "/chunked.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png[:50])
+			w.(http.Flusher).Flush()
+			w.Write(png[50:])
+		}
+	}))
+	t.Cleanup(covers.Close)
+	srv := tests.NewMediaServer(t, "secret")
+
+	for _, path := range []string{"/error.png", "/mislabelled.png", "/large.png", "/chunked.png"} {
+		request := testRequest()
+		request.ImageUrl = covers.URL + path
+		images := ImageOptions{MaxBytes: 64}
+		err := AddMusic(request, "secret", UploadOptions{Mode: CoverStream, Url: srv.Url(), Images: images})
+		var imageErr *ImageError
+		if !errors.As(err, &imageErr) {
+			t.Errorf("%s: err = %v; want an ImageError", path, err)
+		}
+		if path == "/chunked.png" {
+			// Only found out while sending, too late to leave the cover out
+			continue
+		}
+
+		images.AllowMissing = true
+		err = AddMusic(request, "secret", UploadOptions{Mode: CoverStream, Url: srv.Url(), Images: images})
+		uploadErr, coverErr := SplitCoverError(err)
+		if uploadErr != nil || !errors.As(coverErr, &imageErr) {
+			t.Errorf("%s with AllowMissing: err = %v; want an upload without the cover", path, err)
+		}
+	}
+	medias := srv.Medias()
+	if len(medias) != 3 {
+		t.Fatalf("server received %d medias; want 3", len(medias))
+	}
+	for _, media := range medias {
+		if media.Image != nil {
+			t.Errorf("uploaded an image named %q", media.ImageName)
+		}
+	}
+}
+
+func TestAddMusicUrlMode(t *testing.T) {
+	srv := tests.NewMediaServer(t, "secret")
+	request := testRequest()
+	request.Image = "/does/not/matter.png"
+
+	if err := AddMusic(request, "secret", UploadOptions{Mode: CoverUrl, Url: srv.Url()}); err != nil {
+		t.Fatal(err)
+	}
+	media := srv.Medias()[0]
+	checkFields(t, media.Fields, wantFields(request))
+	if media.Image != nil {
+		t.Errorf("url mode sent an image named %q", media.ImageName)
+	}
+}
+
+func TestAddMusicErrors(t *testing.T) {
+	srv := tests.NewMediaServer(t, "secret")
+	opts := UploadOptions{Mode: CoverUrl, Url: srv.Url()}
+
+	if err := AddMusic(testRequest(), "wrong", opts); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("wrong token: err = %v; want a 401 error", err)
+	}
+
+	missing := testRequest()
+	missing.Title = ""
+	if err := AddMusic(missing, "secret", opts); err == nil || !strings.Contains(err.Error(), "missing title") {
+		t.Errorf("missing title: err = %v; want a 400 error", err)
+	}
+
+	srv.Fail(http.StatusInternalServerError)
+	if err := AddMusic(testRequest(), "secret", opts); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("server error: err = %v; want a 500 error", err)
+	}
+
+	if err := AddMusic(testRequest(), "secret", opts); err != nil {
+		t.Errorf("after recovering: %v", err)
+	}
+	if got := len(srv.Medias()); got != 1 {
+		t.Errorf("server stored %d medias; want 1", got)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const maxRecentSearches = 20
+
+// settings are the choices carried over between runs.
+type settings struct {
+	AuthorId     uint   `json:"authorId,omitempty"`
+	CoverMode    string `json:"coverMode,omitempty"`
+	AuthorLookup string `json:"authorLookup,omitempty"`
+}
+
+// session keeps the recent searches in the state dir and the settings in the
+// config dir. Older session files held both.
+type session struct {
+	RecentSearches []services.SearchFilters `json:"recentSearches"`
+	settings
+}
+
+// stateDir follows the XDG base directory spec for state files.
+func stateDir() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "GoFetcher")
+}
+
+func sessionPath() string {
+	dir := stateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "session.json")
+}
+
+func settingsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "GoFetcher", "settings.json")
+}
+
+// loadSession skips empty paths and missing files.
+func loadSession(statePath, configPath string) (session, error) {
+	var s session
+	if err := readJSON(statePath, &s); err != nil {
+		return s, err
+	}
+	err := readJSON(configPath, &s.settings)
+	return s, err
+}
+
+func readJSON(path string, v any) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s session) save(statePath, configPath string) error {
+	state := struct {
+		RecentSearches []services.SearchFilters `json:"recentSearches"`
+	}{s.RecentSearches}
+	if err := writeJSON(statePath, state); err != nil {
+		return err
+	}
+	return writeJSON(configPath, s.settings)
+}
+
+// writeJSON writes v through a temporary file so it is never left truncated.
+func writeJSON(path string, v any) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// addSearch moves filters to the front of the recent searches.
+func (s *session) addSearch(filters services.SearchFilters) {
+	filters.Page = 0
+	recent := []services.SearchFilters{filters}
+	for _, f := range s.RecentSearches {
+		if f != filters && len(recent) < maxRecentSearches {
+			recent = append(recent, f)
+		}
+	}
+	s.RecentSearches = recent
+}
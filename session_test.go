@@ -0,0 +1,49 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSessionSavesStateAndSettingsApart(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state", "session.json")
+	configPath := filepath.Join(dir, "config", "settings.json")
+	want := session{
+		RecentSearches: []services.SearchFilters{{Query: "goo"}},
+		settings:       settings{AuthorId: 7, CoverMode: "url"},
+	}
+	if err := want.save(statePath, configPath); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := os.ReadFile(statePath)
+	if strings.Contains(string(state), "authorId") {
+		t.Errorf("settings saved with the state:\n%s", state)
+	}
+	got, err := loadSession(statePath, configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v; want %+v", got, want)
+	}
+}
+
+func TestSessionReadsOlderFiles(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "session.json")
+	old := `{"recentSearches": [{"Query": "goo"}], "authorId": 7, "coverMode": "url"}`
+	if err := os.WriteFile(statePath, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadSession(statePath, filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AuthorId != 7 || got.CoverMode != "url" || len(got.RecentSearches) != 1 {
+		t.Errorf("loaded %+v", got)
+	}
+}
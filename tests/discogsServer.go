@@ -0,0 +1,335 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Master is a master release served by DiscogsServer.
+type Master struct {
+	Id      int
+	Title   string // "Artist - Title", as Discogs shows it
+	Year    int
+	Genres  []string
+	Styles  []string
+	Formats []string
+	Labels  []string
+	Tracks  []string
+	Notes   string
+	// NoImage leaves the master without images, so only the search
+	// result thumbnail points at a cover.
+	NoImage bool
+}
+
+// DiscogsServer emulates the Discogs API GoFetcher uses, rate limit included.
+type DiscogsServer struct {
+	*httptest.Server
+
+	// BaseUrl, when set, replaces URL in the urls the responses point to,
+	// so recorded responses do not depend on the port.
+	BaseUrl string
+
+	mu       sync.Mutex
+	masters  []Master
+	failures map[string][]int
+	requests []string
+	used     int
+	// limit is the number of requests allowed before 429 responses, 0 for
+	// no limit.
+	limit int
+}
+
+// NewDiscogsServer starts a server holding masters, closed when t finishes.
+func NewDiscogsServer(t testing.TB, masters ...Master) *DiscogsServer {
+	s := &DiscogsServer{masters: masters, failures: make(map[string][]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/database/search", s.search)
+	mux.HandleFunc("/masters/", s.master)
+	mux.HandleFunc("/releases/", s.release)
+	mux.HandleFunc("/artists/", s.artist)
+	mux.HandleFunc("/images/", s.image)
+	s.Server = httptest.NewServer(s.rateLimit(mux))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Fail answers the next requests to path with statuses, one each.
+func (s *DiscogsServer) Fail(path string, statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], statuses...)
+}
+
+// Requests returns the paths and queries requested so far.
+func (s *DiscogsServer) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// SetRateLimit answers requests past the first n with 429, 0 for no limit.
+func (s *DiscogsServer) SetRateLimit(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = n
+}
+
+// Reset clears the rate limit counter.
+func (s *DiscogsServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used = 0
+}
+
+// MasterUrl returns the resource url of the master with id.
+func (s *DiscogsServer) MasterUrl(id int) string {
+	return fmt.Sprintf("%s/masters/%d", s.base(), id)
+}
+
+// ImageUrl returns the url of the cover of the master with id.
+func (s *DiscogsServer) ImageUrl(id int) string {
+	return fmt.Sprintf("%s/images/%d.png", s.base(), id)
+}
+
+func (s *DiscogsServer) base() string {
+	if s.BaseUrl != "" {
+		return s.BaseUrl
+	}
+	return s.URL
+}
+
+func (s *DiscogsServer) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.RequestURI())
+		s.used++
+		used, limit := s.used, s.limit
+		var status int
+		if pending := s.failures[r.URL.Path]; len(pending) > 0 {
+			status, s.failures[r.URL.Path] = pending[0], pending[1:]
+		}
+		s.mu.Unlock()
+
+		if limit > 0 {
+			w.Header().Set("X-Discogs-Ratelimit", strconv.Itoa(limit))
+			w.Header().Set("X-Discogs-Ratelimit-Used", strconv.Itoa(min(used, limit)))
+			w.Header().Set("X-Discogs-Ratelimit-Remaining", strconv.Itoa(max(limit-used, 0)))
+			if used > limit {
+				w.Header().Set("Retry-After", "0")
+				writeJSON(w, http.StatusTooManyRequests, map[string]any{"message": "You are making requests too quickly."})
+				return
+			}
+		}
+		if status != 0 {
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			writeJSON(w, status, map[string]any{"message": http.StatusText(status)})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *DiscogsServer) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.ToLower(query.Get("q"))
+	kind := query.Get("type")
+	var results []any
+	for _, m := range s.masters {
+		if q != "" && !strings.Contains(strings.ToLower(m.Title), q) {
+			continue
+		}
+		if kind != "" && kind != "master" {
+			continue
+		}
+		if year := query.Get("year"); year != "" && year != strconv.Itoa(m.Year) {
+			continue
+		}
+		results = append(results, map[string]any{
+			"id":           m.Id,
+			"type":         "master",
+			"title":        m.Title,
+			"year":         strconv.Itoa(m.Year),
+			"genre":        m.Genres,
+			"style":        m.Styles,
+			"format":       m.Formats,
+			"label":        m.Labels,
+			"resource_url": s.MasterUrl(m.Id),
+			"cover_image":  s.ImageUrl(m.Id),
+			"thumb":        s.ImageUrl(m.Id),
+		})
+	}
+
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage <= 0 {
+		perPage = 50
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	pages := max((len(results)+perPage-1)/perPage, 1)
+	from := min((page-1)*perPage, len(results))
+	to := min(from+perPage, len(results))
+	urls := map[string]any{}
+	if page < pages {
+		next := *r.URL
+		values := next.Query()
+		values.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = values.Encode()
+		urls["next"] = s.base() + next.RequestURI()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"pagination": map[string]any{
+			"page":     page,
+			"pages":    pages,
+			"per_page": perPage,
+			"items":    len(results),
+			"urls":     urls,
+		},
+		"results": append([]any{}, results[from:to]...),
+	})
+}
+
+// find returns the master whose id ends the request path.
+func (s *DiscogsServer) find(w http.ResponseWriter, r *http.Request) (Master, bool) {
+	id, err := strconv.Atoi(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+	if err == nil {
+		for _, m := range s.masters {
+			if m.Id == id {
+				return m, true
+			}
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]any{"message": "Resource not found."})
+	return Master{}, false
+}
+
+func (s *DiscogsServer) master(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	tracks := make([]any, len(m.Tracks))
+	for i, title := range m.Tracks {
+		tracks[i] = map[string]any{"position": strconv.Itoa(i + 1), "title": title}
+	}
+	master := map[string]any{
+		"id":           m.Id,
+		"title":        m.Title,
+		"year":         m.Year,
+		"genres":       m.Genres,
+		"styles":       m.Styles,
+		"tracklist":    tracks,
+		"main_release": m.Id,
+		"resource_url": s.MasterUrl(m.Id),
+	}
+	if m.Notes != "" {
+		master["notes"] = m.Notes
+	}
+	if !m.NoImage {
+		master["images"] = []any{
+			map[string]any{"type": "primary", "uri": s.ImageUrl(m.Id), "uri150": s.ImageUrl(m.Id)},
+		}
+	}
+	writeJSON(w, http.StatusOK, master)
+}
+
+func (s *DiscogsServer) release(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":        m.Id,
+		"title":     m.Title,
+		"year":      m.Year,
+		"genres":    m.Genres,
+		"master_id": m.Id,
+	})
+}
+
+func (s *DiscogsServer) artist(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	name, _, _ := strings.Cut(m.Title, " - ")
+	writeJSON(w, http.StatusOK, map[string]any{"id": m.Id, "name": name})
+}
+
+// image serves a small PNG whose colour depends on the master id.
+func (s *DiscogsServer) image(w http.ResponseWriter, r *http.Request) {
+	r.URL.Path = strings.TrimSuffix(r.URL.Path, ".png")
+	m, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	c := color.RGBA{R: uint8(m.Id * 40), G: 128, B: 255 - uint8(m.Id*40), A: 255}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	_, _ = w.Write(buf.Bytes())
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// SonicYouth returns a few masters matching the records in data.json.
+func SonicYouth() []Master {
+	return []Master{
+		{
+			Id:      1,
+			Title:   "Sonic Youth - Sonic Youth",
+			Year:    1982,
+			Genres:  []string{"Rock"},
+			Styles:  []string{"No Wave"},
+			Formats: []string{"Vinyl", "EP"},
+			Labels:  []string{"Neutral"},
+			Tracks:  []string{"The Burning Spear", "I Dreamed I Dream", "She Is Not Alone", "I Don't Want To Push It", "The Good And The Bad"},
+		},
+		{
+			Id:      2,
+			Title:   "Sonic Youth - Daydream Nation",
+			Year:    1988,
+			Genres:  []string{"Rock"},
+			Styles:  []string{"Noise", "Alternative Rock"},
+			Formats: []string{"Vinyl", "LP", "Album"},
+			Labels:  []string{"Enigma Records", "Blast First"},
+			Tracks:  []string{"Teen Age Riot", "Silver Rocket", "The Sprawl"},
+			Notes:   "Double album.",
+		},
+		{
+			Id:      3,
+			Title:   "Sonic Youth - Goo",
+			Year:    1990,
+			Genres:  []string{"Rock"},
+			Styles:  []string{"Alternative Rock"},
+			Formats: []string{"CD", "Album"},
+			Labels:  []string{"DGC"},
+			Tracks:  []string{"Dirty Boots", "Tunic (Song For Karen)", "Kool Thing"},
+			NoImage: true,
+		},
+	}
+}
@@ -1,7 +0,0 @@
-package tests
-
-//func main(){
-//	records :=[]services.Record{{
-//		""
-//	}, {}}
-//}
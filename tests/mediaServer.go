@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Media is a record received by MediaServer.
+type Media struct {
+	Id            int
+	Fields        map[string]string
+	Image         []byte // nil when no image part was sent
+	ImageName     string
+	ContentLength int64
+}
+
+// requiredFields must be present and non-empty in every upload.
+var requiredFields = []string{"title", "type", "authorId", "releaseDate"}
+
+// MediaServer stands in for the /medias endpoint, checking token and fields.
+type MediaServer struct {
+	*httptest.Server
+
+	// Token is the bearer token uploads must carry.
+	Token string
+
+	mu       sync.Mutex
+	medias   []Media
+	failures []int
+}
+
+// NewMediaServer starts a server accepting token, closed when t finishes.
+func NewMediaServer(t testing.TB, token string) *MediaServer {
+	s := &MediaServer{Token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/medias", s.upload)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Url returns the endpoint to pass to AddMusic.
+func (s *MediaServer) Url() string {
+	return s.URL + "/medias"
+}
+
+// Fail answers the next uploads with statuses, one each.
+func (s *MediaServer) Fail(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Medias returns the media accepted so far.
+func (s *MediaServer) Medias() []Media {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Media(nil), s.medias...)
+}
+
+func (s *MediaServer) upload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"message": "method not allowed"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"message": "invalid token"})
+		return
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": err.Error()})
+		return
+	}
+	media := Media{Fields: make(map[string]string), ContentLength: r.ContentLength}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"message": err.Error()})
+			return
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"message": err.Error()})
+			return
+		}
+		if part.FileName() != "" {
+			media.Image, media.ImageName = data, part.FileName()
+			continue
+		}
+		media.Fields[part.FormName()] = string(data)
+	}
+
+	var missing []string
+	for _, field := range requiredFields {
+		if media.Fields[field] == "" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": "missing " + strings.Join(missing, ", ")})
+		return
+	}
+	if _, err := strconv.ParseUint(media.Fields["authorId"], 10, 0); err != nil || media.Fields["authorId"] == "0" {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"message": "invalid authorId"})
+		return
+	}
+
+	s.mu.Lock()
+	var status int
+	if len(s.failures) > 0 {
+		status, s.failures = s.failures[0], s.failures[1:]
+	}
+	if status == 0 {
+		media.Id = len(s.medias) + 1
+		s.medias = append(s.medias, media)
+	}
+	s.mu.Unlock()
+	if status != 0 {
+		writeJSON(w, status, map[string]any{"message": http.StatusText(status)})
+		return
+	}
+	response := map[string]any{"id": media.Id}
+	for k, v := range media.Fields {
+		response[k] = v
+	}
+	w.Header().Set("Location", "/medias/"+strconv.Itoa(media.Id))
+	writeJSON(w, http.StatusCreated, response)
+}
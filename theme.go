@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// theme holds the styles of every screen.
+type theme struct {
+	Name string
+
+	Doc     lipgloss.Style // margin around full screen views
+	Detail  lipgloss.Style // preview pane next to the release list
+	Heading lipgloss.Style // titles and the record name in the preview
+	Focused lipgloss.Style // focused form field and spinner
+	Muted   lipgloss.Style // secondary text
+	Success lipgloss.Style
+	Warning lipgloss.Style
+	Error   lipgloss.Style
+
+	ListTitle    lipgloss.Style
+	Selected     lipgloss.Color // selected list item and its border
+	SelectedDesc lipgloss.Color
+	Normal       lipgloss.Color
+	NormalDesc   lipgloss.Color
+
+	ProgressFrom string
+	ProgressTo   string
+}
+
+func baseTheme(name string) theme {
+	return theme{
+		Name:   name,
+		Doc:    lipgloss.NewStyle().Margin(1, 2),
+		Detail: lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1),
+	}
+}
+
+func darkTheme() theme {
+	t := baseTheme("dark")
+	t.Detail = t.Detail.BorderForeground(lipgloss.Color("240"))
+	t.Heading = lipgloss.NewStyle().Bold(true)
+	t.Focused = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	t.Muted = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	t.Success = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	t.Warning = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	t.Error = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+	t.ListTitle = lipgloss.NewStyle().Background(lipgloss.Color("62")).Foreground(lipgloss.Color("230")).Padding(0, 1)
+	t.Selected = lipgloss.Color("#EE6FF8")
+	t.SelectedDesc = lipgloss.Color("#AD58B4")
+	t.Normal = lipgloss.Color("#DDDDDD")
+	t.NormalDesc = lipgloss.Color("#777777")
+	t.ProgressFrom, t.ProgressTo = "#5A56E0", "#EE6FF8"
+	return t
+}
+
+func lightTheme() theme {
+	t := baseTheme("light")
+	t.Detail = t.Detail.BorderForeground(lipgloss.Color("250"))
+	t.Heading = lipgloss.NewStyle().Bold(true)
+	t.Focused = lipgloss.NewStyle().Foreground(lipgloss.Color("161"))
+	t.Muted = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	t.Success = lipgloss.NewStyle().Foreground(lipgloss.Color("28"))
+	t.Warning = lipgloss.NewStyle().Foreground(lipgloss.Color("130"))
+	t.Error = lipgloss.NewStyle().Foreground(lipgloss.Color("160"))
+	t.ListTitle = lipgloss.NewStyle().Background(lipgloss.Color("62")).Foreground(lipgloss.Color("255")).Padding(0, 1)
+	t.Selected = lipgloss.Color("#B0179F")
+	t.SelectedDesc = lipgloss.Color("#C04BB0")
+	t.Normal = lipgloss.Color("#1A1A1A")
+	t.NormalDesc = lipgloss.Color("#A49FA5")
+	t.ProgressFrom, t.ProgressTo = "#3C38B8", "#B0179F"
+	return t
+}
+
+func highContrastTheme() theme {
+	t := baseTheme("high-contrast")
+	t.Detail = t.Detail.Border(lipgloss.ThickBorder()).BorderForeground(lipgloss.Color("15"))
+	t.Heading = lipgloss.NewStyle().Bold(true).Underline(true)
+	t.Focused = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
+	t.Muted = lipgloss.NewStyle().Foreground(lipgloss.Color("15"))
+	t.Success = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("10"))
+	t.Warning = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
+	t.Error = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("9"))
+	t.ListTitle = lipgloss.NewStyle().Bold(true).Reverse(true).Padding(0, 1)
+	t.Selected = lipgloss.Color("11")
+	t.SelectedDesc = lipgloss.Color("11")
+	t.Normal = lipgloss.Color("15")
+	t.NormalDesc = lipgloss.Color("15")
+	t.ProgressFrom, t.ProgressTo = "#FFFF00", "#FFFF00"
+	return t
+}
+
+// monoTheme is used when NO_COLOR is set and relies on text attributes only.
+func monoTheme() theme {
+	t := baseTheme("mono")
+	t.Heading = lipgloss.NewStyle().Bold(true)
+	t.Focused = lipgloss.NewStyle().Bold(true).Underline(true)
+	t.Muted = lipgloss.NewStyle().Faint(true)
+	t.Success = lipgloss.NewStyle()
+	t.Warning = lipgloss.NewStyle().Bold(true)
+	t.Error = lipgloss.NewStyle().Bold(true)
+	t.ListTitle = lipgloss.NewStyle().Reverse(true).Padding(0, 1)
+	return t
+}
+
+var themes = map[string]func() theme{
+	"dark":          darkTheme,
+	"light":         lightTheme,
+	"high-contrast": highContrastTheme,
+	"mono":          monoTheme,
+}
+
+func themeNames() string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// selectTheme resolves -theme. NO_COLOR means mono unless a theme is given
+// explicitly, as no-color.org asks.
+func selectTheme(name string, explicit bool, getenv func(string) string, darkBackground func() bool) (theme, error) {
+	switch {
+	case getenv("NO_COLOR") != "" && (!explicit || name == "auto"):
+		name = "mono"
+	case name == "" || name == "auto":
+		if darkBackground() {
+			name = "dark"
+		} else {
+			name = "light"
+		}
+	}
+	newTheme, ok := themes[name]
+	if !ok {
+		return theme{}, fmt.Errorf("unknown theme %q (want auto, %s)", name, themeNames())
+	}
+	return newTheme(), nil
+}
+
+// setTheme applies t to the model and its components.
+func (m *model) setTheme(t theme) {
+	m.theme = t
+	m.spinner.Style = t.Focused
+
+	m.list.Styles.Title = t.ListTitle
+	d := newCheckboxDelegate(m.selected)
+	if t.Selected != "" {
+		d.Styles.SelectedTitle = d.Styles.SelectedTitle.Foreground(t.Selected).BorderForeground(t.Selected)
+		d.Styles.SelectedDesc = d.Styles.SelectedDesc.Foreground(t.SelectedDesc).BorderForeground(t.Selected)
+		d.Styles.NormalTitle = d.Styles.NormalTitle.Foreground(t.Normal)
+		d.Styles.NormalDesc = d.Styles.NormalDesc.Foreground(t.NormalDesc)
+	} else {
+		// Without colours the selected item is marked by its border and weight
+		d.Styles.SelectedTitle = d.Styles.SelectedTitle.UnsetForeground().UnsetBorderForeground().Bold(true)
+		d.Styles.SelectedDesc = d.Styles.SelectedDesc.UnsetForeground().UnsetBorderForeground()
+		d.Styles.NormalTitle = d.Styles.NormalTitle.UnsetForeground()
+		d.Styles.NormalDesc = d.Styles.NormalDesc.UnsetForeground().Faint(true)
+	}
+	m.list.SetDelegate(d)
+	m.list.Styles.StatusBar = m.list.Styles.StatusBar.Inherit(t.Muted)
+
+	m.help.Styles.ShortKey = t.Focused
+	m.help.Styles.ShortDesc = t.Muted
+	m.help.Styles.ShortSeparator = t.Muted
+	m.help.Styles.FullKey = t.Focused
+	m.help.Styles.FullDesc = t.Muted
+	m.help.Styles.FullSeparator = t.Muted
+
+	width := m.progress.Width
+	if t.ProgressFrom != "" {
+		m.progress = progress.New(progress.WithGradient(t.ProgressFrom, t.ProgressTo))
+	} else {
+		m.progress = progress.New(progress.WithSolidFill(""), progress.WithoutPercentage())
+		m.progress.Full, m.progress.Empty = '#', '-'
+	}
+	m.progress.Width = width
+}
+
+// resultStyle picks the style for a line of the import summary.
+func (t theme) resultStyle(failed, warned bool) lipgloss.Style {
+	switch {
+	case failed:
+		return t.Error
+	case warned:
+		return t.Warning
+	}
+	return t.Success
+}
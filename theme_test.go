@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestSelectTheme(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		explicit bool
+		noColor  string
+		want     string
+	}{
+		{"auto", false, "", "dark"},
+		{"auto", false, "1", "mono"},
+		{"auto", true, "1", "mono"},
+		{"light", true, "", "light"},
+		{"high-contrast", true, "1", "high-contrast"},
+		{"mono", true, "", "mono"},
+	} {
+		env := map[string]string{"NO_COLOR": tc.noColor}
+		got, err := selectTheme(tc.name, tc.explicit, func(key string) string { return env[key] }, func() bool { return true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != tc.want {
+			t.Errorf("selectTheme(%q, %v) with NO_COLOR=%q = %s; want %s", tc.name, tc.explicit, tc.noColor, got.Name, tc.want)
+		}
+	}
+	if _, err := selectTheme("neon", true, func(string) string { return "" }, func() bool { return true }); err == nil {
+		t.Error("selectTheme accepted an unknown theme")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// traceFlags adds the trace flags to fs; call the result once fs is parsed.
+func traceFlags(fs *flag.FlagSet) func() (*services.Tracer, func() error) {
+	enabled := fs.Bool("trace", false, "log method, url, status, timing, rate limit headers and truncated bodies of every request to Discogs and the media service")
+	har := fs.String("trace-har", "", "write the traced requests to this HAR file when done (implies -trace)")
+	body := fs.Int("trace-body", 2048, "bytes of each request and response body kept in the trace")
+	return func() (*services.Tracer, func() error) {
+		if !*enabled && *har == "" {
+			return nil, func() error { return nil }
+		}
+		tracer := services.NewTracer(*body, 1000)
+		services.SetTracer(tracer)
+		return tracer, func() error {
+			if *har == "" {
+				return nil
+			}
+			return writeHAR(*har, tracer)
+		}
+	}
+}
+
+func writeHAR(path string, tracer *services.Tracer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := tracer.WriteHAR(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (m *model) traceKey(msg tea.KeyMsg) {
+	entries := len(m.tracer.Entries())
+	cursor := m.traceSelected(entries)
+	switch {
+	case key.Matches(msg, m.keys.HistoryPrev):
+		m.traceCursor, m.tracePinned = max(cursor-1, 0), true
+	case key.Matches(msg, m.keys.HistoryNext):
+		m.traceCursor = min(cursor+1, entries-1)
+		// Back on the newest request, follow new ones again
+		m.tracePinned = m.traceCursor < entries-1
+	}
+}
+
+// traceSelected is the newest request until another is picked.
+func (m *model) traceSelected(entries int) int {
+	if !m.tracePinned || m.traceCursor >= entries {
+		return entries - 1
+	}
+	return m.traceCursor
+}
+
+func (m *model) traceView() string {
+	entries := m.tracer.Entries()
+	var b strings.Builder
+	b.WriteString(m.theme.Heading.Render("HTTP trace") + "\n\n")
+	if len(entries) == 0 {
+		b.WriteString(m.theme.Muted.Render("No requests yet") + "\n")
+	}
+	width := max(m.help.Width, 40)
+	cursor := m.traceSelected(len(entries))
+
+	rows := max(m.paneHeight/3, 3)
+	start := max(min(cursor-rows/2, len(entries)-rows), 0)
+	for i := start; i < len(entries) && i < start+rows; i++ {
+		e := entries[i]
+		line := fmt.Sprintf("%-3s %-4s %5dms  %s", traceStatus(e), e.Method, e.Duration.Milliseconds(), e.Url)
+		line = truncateLine(line, width-2)
+		style := m.theme.Muted
+		switch {
+		case e.Status == 0 || e.Status >= 500:
+			style = m.theme.Error
+		case e.Status >= 400:
+			style = m.theme.Warning
+		}
+		if i == cursor {
+			b.WriteString("> " + style.Bold(true).Render(line) + "\n")
+		} else {
+			b.WriteString("  " + style.Render(line) + "\n")
+		}
+	}
+
+	if cursor >= 0 {
+		b.WriteString("\n" + m.traceDetails(entries[cursor], width, max(m.paneHeight-rows-8, 2)))
+	}
+	help := helpKeys{withHelpDesc(m.keys.HistoryPrev, "older"), withHelpDesc(m.keys.HistoryNext, "newer"),
+		withHelpDesc(m.keys.Trace, "close trace"), m.keys.Quit}
+	return m.theme.Doc.Render(b.String() + m.help.View(help))
+}
+
+func (m *model) traceDetails(e services.TraceEntry, width, height int) string {
+	lines := []string{m.theme.Heading.Render(e.Method + " " + truncateLine(e.Url, width-len(e.Method)-1))}
+	if e.Err != "" {
+		lines = append(lines, m.theme.Error.Render(truncateLine(e.Err, width)))
+	}
+	lines = append(lines, m.theme.Muted.Render(fmt.Sprintf("%s, sent %d bytes, received %d bytes",
+		e.Start.Format("15:04:05.000"), e.RequestSize, e.ResponseSize)))
+	if limits := e.RateLimit(); len(limits) > 0 {
+		lines = append(lines, m.theme.Muted.Render(truncateLine(strings.Join(limits, "  "), width)))
+	}
+	for _, body := range []struct{ name, text string }{{"request", e.RequestBody}, {"response", e.ResponseBody}} {
+		if body.text == "" {
+			continue
+		}
+		lines = append(lines, m.theme.Heading.Render(body.name+" body"))
+		for _, line := range strings.Split(strings.ReplaceAll(body.text, "\r\n", "\n"), "\n") {
+			lines = append(lines, truncateLine(line, width))
+		}
+	}
+	if len(lines) > height {
+		lines = append(lines[:height-1], m.theme.Muted.Render("…"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...) + "\n"
+}
+
+func traceStatus(e services.TraceEntry) string {
+	if e.Status == 0 {
+		return "ERR"
+	}
+	return fmt.Sprint(e.Status)
+}
+
+func truncateLine(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:max(width-1, 0)]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"GoFetcher/tests"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// harness drives a model the way tea.Program would, running the commands it
+// returns and feeding their messages back until it settles. Animation ticks
+// are dropped so every run renders the same frames.
+type harness struct {
+	t    *testing.T
+	m    *model
+	quit bool
+	// replace maps volatile strings such as server urls to stable ones
+	replace *strings.Replacer
+}
+
+func newHarness(t *testing.T, m *model, replace *strings.Replacer) *harness {
+	lipgloss.SetColorProfile(termenv.Ascii)
+	m.graphics = graphicsNone
+	m.list.StatusMessageLifetime = 0
+	m.ti.Cursor.SetMode(cursor.CursorHide)
+	m.list.FilterInput.Cursor.SetMode(cursor.CursorHide)
+	for i := range m.form.inputs {
+		m.form.inputs[i].Cursor.SetMode(cursor.CursorHide)
+	}
+	h := &harness{t: t, m: m, replace: replace}
+	h.send(tea.WindowSizeMsg{Width: 100, Height: 24})
+	return h
+}
+
+func (h *harness) send(msg tea.Msg) {
+	h.t.Helper()
+	h.settle(h.step(msg))
+}
+
+// step updates the model with msg without running the resulting command,
+// so the screen shown while it runs can be checked.
+func (h *harness) step(msg tea.Msg) tea.Cmd {
+	_, cmd := h.m.Update(msg)
+	return cmd
+}
+
+// settle runs cmd and delivers the messages that follow from it.
+func (h *harness) settle(cmd tea.Cmd) {
+	h.t.Helper()
+	pending := h.run(cmd)
+	for steps := 0; len(pending) > 0; steps++ {
+		if steps > 1000 {
+			h.t.Fatal("model did not settle after 1000 messages")
+		}
+		var msg tea.Msg
+		msg, pending = pending[0], pending[1:]
+		pending = append(pending, h.run(h.step(msg))...)
+	}
+}
+
+// run executes cmd and returns the messages worth delivering.
+func (h *harness) run(cmd tea.Cmd) []tea.Msg {
+	h.t.Helper()
+	if cmd == nil {
+		return nil
+	}
+	done := make(chan tea.Msg, 1)
+	go func() { done <- cmd() }()
+	var msg tea.Msg
+	select {
+	case msg = <-done:
+	case <-time.After(5 * time.Second):
+		h.t.Fatal("command did not finish within 5s")
+	}
+	switch msg := msg.(type) {
+	case nil, spinner.TickMsg, cursor.BlinkMsg, sixelDrawMsg:
+		return nil
+	case tea.QuitMsg:
+		h.quit = true
+		return nil
+	case tea.BatchMsg:
+		var msgs []tea.Msg
+		for _, cmd := range msg {
+			msgs = append(msgs, h.run(cmd)...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+// typeText sends s one rune at a time.
+func (h *harness) typeText(s string) {
+	h.t.Helper()
+	for _, r := range s {
+		h.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+func (h *harness) press(keys ...tea.KeyType) {
+	h.t.Helper()
+	for _, k := range keys {
+		h.send(tea.KeyMsg{Type: k})
+	}
+}
+
+// expect checks the state and compares the rendered view to the golden
+// file tui_<name>.golden.
+func (h *harness) expect(state State, name string) {
+	h.t.Helper()
+	if h.m.state != state {
+		h.t.Fatalf("%s: state = %d; want %d", name, h.m.state, state)
+	}
+	view := h.replace.Replace(h.m.View())
+	// Trailing spaces depend on padding only and make the files hard to edit
+	lines := strings.Split(view, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	golden(h.t, fmt.Sprintf("tui_%s.golden", name), strings.Join(lines, "\n"))
+}
+
+// maskPort hides the port of a test server url without changing its
+// length, so list columns line up the same on every run.
+func maskPort(url string) *strings.Replacer {
+	i := strings.LastIndex(url, ":")
+	return strings.NewReplacer(url, url[:i+1]+strings.Repeat("X", len(url)-i-1))
+}
+
+func newTestModel(discogs *tests.DiscogsServer, media *tests.MediaServer) *model {
+	m := initialModel()
+	m.searcher = &services.Discogs{BaseUrl: discogs.URL}
+	m.fetcher = &services.MasterFetcher{}
+	m.sink = &services.MediaService{Url: media.Url(), Mode: services.CoverUrl}
+	m.coverMode = services.CoverUrl
+	m.exportPath = ""
+	return m
+}
+
+func TestTUIImport(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	media := tests.NewMediaServer(t, "secret-token")
+	h := newHarness(t, newTestModel(discogs, media), maskPort(discogs.URL))
+
+	h.expect(InputArtist, "01_search")
+	h.typeText("Sonic Youth")
+	h.press(tea.KeyEnter)
+	h.expect(InputAuthorId, "02_author")
+
+	h.typeText("7x")
+	h.expect(InputAuthorId, "03_author_invalid")
+	h.press(tea.KeyBackspace, tea.KeyEnter)
+	h.expect(InputToken, "04_token")
+
+	h.typeText("secret-token")
+	h.press(tea.KeyEnter)
+	h.expect(SelectReleases, "05_releases")
+
+	// Select Goo and Daydream Nation, skipping the first record
+	h.press(tea.KeyDown)
+	h.typeText("x")
+	h.press(tea.KeyDown)
+	h.typeText("x ")
+	h.expect(ConfirmReleases, "06_confirm")
+
+	cmd := h.step(tea.KeyMsg{Type: tea.KeyEnter})
+	h.expect(Fetching, "07_fetching")
+	h.settle(cmd)
+	h.expect(Done, "08_done")
+	medias := media.Medias()
+	if len(medias) != 2 {
+		t.Fatalf("media service received %d records; want 2", len(medias))
+	}
+	for _, m := range medias {
+		if m.Fields["authorId"] != "7" {
+			t.Errorf("%s uploaded with author %s; want 7", m.Fields["title"], m.Fields["authorId"])
+		}
+	}
+
+	h.press(tea.KeyEsc)
+	if !h.quit {
+		t.Error("esc on the Done screen did not quit")
+	}
+}
+
+func TestTUIBackAndNewSearch(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	media := tests.NewMediaServer(t, "secret-token")
+	h := newHarness(t, newTestModel(discogs, media), maskPort(discogs.URL))
+
+	h.typeText("goo")
+	h.press(tea.KeyEnter)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+	h.press(tea.KeyEsc)
+	// The author ID entered before is kept when going back
+	h.expect(InputAuthorId, "back_author")
+
+	h.press(tea.KeyEnter)
+	h.typeText("secret-token")
+	h.press(tea.KeyEnter)
+	h.typeText("x ")
+	discogs.Fail("/masters/3", 500)
+	h.press(tea.KeyEnter)
+	h.expect(Done, "back_done")
+	if got := len(media.Medias()); got != 0 {
+		t.Errorf("media service received %d records; want none", got)
+	}
+
+	h.typeText("n")
+	h.expect(InputArtist, "back_new_search")
+}
+
+func TestTUIFormKeysWithoutHistory(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	media := tests.NewMediaServer(t, "secret-token")
+	h := newHarness(t, newTestModel(discogs, media), maskPort(discogs.URL))
+
+	// Up and down move between fields until there is something to browse
+	h.press(tea.KeyDown)
+	if h.m.form.focus != fieldQuery+1 {
+		t.Fatalf("down on the query moved focus to %d; want the next field", h.m.form.focus)
+	}
+	h.press(tea.KeyUp)
+	if h.m.form.focus != fieldQuery {
+		t.Fatalf("up moved focus to %d; want the query", h.m.form.focus)
+	}
+
+	h.m.form.recent = []services.SearchFilters{{Query: "goo"}}
+	h.press(tea.KeyUp)
+	if h.m.form.focus != fieldQuery || h.m.form.inputs[fieldQuery].Value() != "goo" {
+		t.Errorf("up with history: focus %d, query %q; want the last search", h.m.form.focus, h.m.form.inputs[fieldQuery].Value())
+	}
+}
+
+func TestTUISelectAllKeepsToFilter(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	media := tests.NewMediaServer(t, "secret-token")
+	h := newHarness(t, newTestModel(discogs, media), maskPort(discogs.URL))
+	h.typeText("Sonic Youth")
+	h.press(tea.KeyEnter)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+	h.typeText("secret-token")
+	h.press(tea.KeyEnter)
+
+	h.typeText("/daydream")
+	h.press(tea.KeyEnter)
+	h.typeText("a")
+	if len(h.m.selected) != 1 {
+		t.Fatalf("selected %d records; want only Daydream Nation", len(h.m.selected))
+	}
+	h.typeText(" ")
+	if h.m.state != ConfirmReleases || len(h.m.choices) != 1 || h.m.choices[0].Title() != "Sonic Youth - Daydream Nation" {
+		t.Errorf("reviewing %d records in state %d; want only Daydream Nation", len(h.m.choices), h.m.state)
+	}
+}
+
+func TestTUIPrintableQuitKeyIsTyped(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	media := tests.NewMediaServer(t, "secret-token")
+	m := newTestModel(discogs, media)
+	m.keys.Quit.SetKeys("ctrl+c", "q")
+	h := newHarness(t, m, maskPort(discogs.URL))
+	h.typeText("Sonic Youth q")
+	if h.quit || h.m.form.Filters().Query != "Sonic Youth q" {
+		t.Fatalf("query = %q, quit = %v; want the q typed", h.m.form.Filters().Query, h.quit)
+	}
+	h.press(tea.KeyBackspace, tea.KeyEnter)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+	h.typeText("secret-token")
+	h.press(tea.KeyEnter)
+
+	h.typeText("/q")
+	if h.quit {
+		t.Fatal("q quit while filtering")
+	}
+	h.press(tea.KeyEsc)
+	h.typeText("q")
+	if !h.quit {
+		t.Error("q did not quit the release list")
+	}
+}
+
+// fakeFetcher serves masters from memory, keyed by record url.
+type fakeFetcher map[string]map[string]any
+
+func (f fakeFetcher) Fetch(record services.Record) (any, *services.Image, error) {
+	master, ok := f[record.Url()]
+	if !ok {
+		return nil, nil, fmt.Errorf("no master at %s", record.Url())
+	}
+	return master, nil, nil
+}
+
+func (f fakeFetcher) Details(record services.Record) (services.Details, error) {
+	return record.Details(), nil
+}
+
+// fakeSink records what it is sent and rejects the titles in fail.
+type fakeSink struct {
+	sent []services.Request
+	fail map[string]bool
+}
+
+func (s *fakeSink) Send(req services.Request, token string, progress func(sent, total int64)) error {
+	if s.fail[req.Title] {
+		return fmt.Errorf("rejected %s", req.Title)
+	}
+	s.sent = append(s.sent, req)
+	return nil
+}
+
+func TestTUIImportWithFakes(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	m := newTestModel(discogs, tests.NewMediaServer(t, "secret-token"))
+	m.fetcher = fakeFetcher{
+		discogs.MasterUrl(1): {"title": "Sonic Youth"},
+		discogs.MasterUrl(2): {"title": "Daydream Nation", "year": 1988.0},
+	}
+	sink := &fakeSink{fail: map[string]bool{"Sonic Youth": true}}
+	m.sink = sink
+	h := newHarness(t, m, maskPort(discogs.URL))
+	h.typeText("Sonic Youth")
+	h.press(tea.KeyEnter)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+	h.typeText("secret-token")
+	h.press(tea.KeyEnter)
+	h.typeText("a ")
+	h.press(tea.KeyEnter)
+
+	if h.m.state != Done {
+		t.Fatalf("state = %d; want Done", h.m.state)
+	}
+	var lines []string
+	for _, result := range h.m.results {
+		lines = append(lines, h.replace.Replace(result.String()))
+	}
+	want := []string{
+		"✗ Sonic Youth - Sonic Youth: rejected Sonic Youth",
+		"✓ Sonic Youth - Daydream Nation",
+		"✗ Sonic Youth - Goo: no master at " + maskPort(discogs.URL).Replace(discogs.MasterUrl(3)),
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("results:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+	if len(sink.sent) != 1 || sink.sent[0].AuthorId != 7 || sink.sent[0].ReleaseDate != "1988-01-01" {
+		t.Errorf("sink received %+v", sink.sent)
+	}
+
+	h.press(tea.KeyBackspace)
+	if h.quit || h.m.state != Done {
+		t.Fatal("backspace left the Done screen")
+	}
+	h.press(tea.KeyEsc)
+	if !h.quit {
+		t.Error("esc did not quit from the Done screen")
+	}
+}
+
+func TestTUIUploadProgress(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	m := newTestModel(discogs, tests.NewMediaServer(t, "secret-token"))
+	m.fetcher = fakeFetcher{}
+	h := newHarness(t, m, maskPort(discogs.URL))
+	h.typeText("Sonic Youth")
+	h.press(tea.KeyEnter)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+	h.typeText("secret-token")
+	h.press(tea.KeyEnter)
+	h.typeText("a ")
+
+	// The import and the wait for its next event are left pending
+	h.step(tea.KeyMsg{Type: tea.KeyEnter})
+	h.step(uploadProgressMsg{item: 1, title: "Sonic Youth - Daydream Nation", sent: 256, total: 1024})
+	h.expect(Fetching, "upload_progress")
+}
+
+func TestTUIExportFromDone(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	m := newTestModel(discogs, tests.NewMediaServer(t, "secret-token"))
+	m.fetcher = fakeFetcher{
+		discogs.MasterUrl(1): {"title": "Sonic Youth"},
+		discogs.MasterUrl(2): {"title": "Daydream Nation", "year": 1988.0},
+	}
+	m.sink = &fakeSink{fail: map[string]bool{"Sonic Youth": true}}
+	dir := t.TempDir()
+	m.exportPath = filepath.Join(dir, "releases.csv")
+	m.exportOpts = services.ExportOptions{Columns: []string{"title", "releaseDate", "authorId"}}
+	h := newHarness(t, m, maskPort(discogs.URL))
+	h.typeText("Sonic Youth")
+	h.press(tea.KeyEnter)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+	h.typeText("secret-token")
+	h.press(tea.KeyEnter)
+	h.typeText("a ")
+	h.press(tea.KeyEnter)
+
+	h.typeText("e")
+	if h.m.state != Done {
+		t.Fatalf("state = %d; want Done", h.m.state)
+	}
+	status := "Exported 1 records to " + m.exportPath
+	if !strings.Contains(h.m.View(), status) {
+		t.Errorf("view lacks %q:\n%s", status, h.m.View())
+	}
+	data, err := os.ReadFile(m.exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "title,releaseDate,authorId\nDaydream Nation,1988-01-01,7\n"; string(data) != want {
+		t.Errorf("export = %q; want %q", data, want)
+	}
+
+	// A failed export is reported and the results stay on screen
+	m.exportPath = filepath.Join(dir, "missing", "releases.csv")
+	h.typeText("e")
+	if h.m.state != Done || h.m.exported.err == nil || !strings.Contains(h.m.View(), "no such file or directory") {
+		t.Errorf("failed export not shown:\n%s", h.m.View())
+	}
+}
+
+func TestTUIAuthorLookup(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	var auth string
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.URL.Path != "/users/7" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"name": "Kim"}`))
+	}))
+	defer users.Close()
+	m := newTestModel(discogs, tests.NewMediaServer(t, "secret-token"))
+	m.searcher = &services.Discogs{BaseUrl: discogs.URL, Token: "discogs-token"}
+	m.authorLookup = users.URL + "/users/%d"
+	m.lookupToken = "media-token"
+	h := newHarness(t, m, maskPort(discogs.URL))
+	h.typeText("Sonic Youth")
+	h.press(tea.KeyEnter)
+
+	// An unknown author blocks the screen
+	h.typeText("8")
+	h.press(tea.KeyEnter)
+	h.press(tea.KeyEnter)
+	if h.m.state != InputAuthorId || !errors.Is(h.m.inputErr, services.ErrAuthorNotFound) {
+		t.Fatalf("state = %d, err = %v; want author 8 rejected", h.m.state, h.m.inputErr)
+	}
+
+	h.press(tea.KeyBackspace)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+	if h.m.author.name != "Kim" || auth != "Bearer media-token" {
+		t.Errorf("author %q looked up with %q; want Kim with the media token", h.m.author.name, auth)
+	}
+	h.press(tea.KeyEnter)
+	if h.m.state != InputToken || h.m.authorId != 7 {
+		t.Errorf("state = %d, author = %d; want the token input for author 7", h.m.state, h.m.authorId)
+	}
+}
+
+func TestTUIAuthorLookupUnreachable(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	users := httptest.NewServer(http.NotFoundHandler())
+	users.Close()
+	m := newTestModel(discogs, tests.NewMediaServer(t, "secret-token"))
+	m.authorLookup = users.URL + "/users/%d"
+	h := newHarness(t, m, maskPort(discogs.URL))
+	h.typeText("Sonic Youth")
+	h.press(tea.KeyEnter)
+	h.typeText("7")
+	h.press(tea.KeyEnter)
+
+	if h.m.state != InputAuthorId || h.m.inputErr != nil || !strings.Contains(h.m.inputStatus(), "author not confirmed") {
+		t.Fatalf("state = %d, err = %v, status %q; want a warning", h.m.state, h.m.inputErr, h.m.inputStatus())
+	}
+	h.press(tea.KeyEnter)
+	if h.m.state != InputToken || h.m.authorId != 7 {
+		t.Errorf("state = %d, author = %d; want the token input for author 7", h.m.state, h.m.authorId)
+	}
+}
+
+func TestTUILogViewer(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	m := newTestModel(discogs, tests.NewMediaServer(t, "secret-token"))
+	m.logs = newLogRing(10)
+	m.logs.Write([]byte("level=INFO msg=\"search done\" results=3\nlevel=WARN msg=\"cover missing\"\n"))
+	h := newHarness(t, m, maskPort(discogs.URL))
+	h.typeText("Sonic")
+
+	// The viewer ticks while open, so the command is not run
+	h.step(tea.KeyMsg{Type: tea.KeyCtrlL})
+	h.expect(InputArtist, "logs")
+	h.typeText("x")
+	if h.m.form.Filters().Query != "Sonic" {
+		t.Errorf("query = %q; want keys kept from the form while the logs are open", h.m.form.Filters().Query)
+	}
+	h.press(tea.KeyEsc)
+	if h.m.showLogs || h.quit {
+		t.Error("esc did not close the log viewer")
+	}
+}
+
+func TestTUITracePanel(t *testing.T) {
+	discogs := tests.NewDiscogsServer(t, tests.SonicYouth()...)
+	m := newTestModel(discogs, tests.NewMediaServer(t, "secret-token"))
+	discogs.SetRateLimit(60)
+	m.tracer = services.NewTracer(64, 0)
+	services.SetTracer(m.tracer)
+	t.Cleanup(func() { services.SetTracer(nil) })
+	fetch := func(id int) {
+		resp, err := services.SendRequest(discogs.MasterUrl(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	for id := 1; id <= 3; id++ {
+		fetch(id)
+	}
+	h := newHarness(t, m, maskPort(discogs.URL))
+	// selected returns the highlighted row and the detail heading
+	selected := func() (string, string) {
+		var row, heading string
+		for _, line := range strings.Split(h.m.View(), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "> ") {
+				row = line
+			} else if row != "" && heading == "" && strings.HasPrefix(line, "GET ") {
+				heading = line
+			}
+		}
+		return row, heading
+	}
+	expectSelected := func(id int) {
+		t.Helper()
+		url := discogs.MasterUrl(id)
+		row, heading := selected()
+		if !strings.HasSuffix(row, url) || heading != "GET "+url {
+			t.Errorf("selected %q with details %q; want %s", row, heading, url)
+		}
+	}
+
+	h.step(tea.KeyMsg{Type: tea.KeyCtrlT})
+	if !h.m.showTrace {
+		t.Fatal("ctrl+t did not open the trace panel")
+	}
+	expectSelected(3)
+	if view := h.m.View(); !strings.Contains(view, "response body") || !strings.Contains(view, "X-Discogs-Ratelimit") {
+		t.Errorf("details lack the response body or rate limit:\n%s", view)
+	}
+
+	// An older request stays selected while new ones come in
+	h.press(tea.KeyUp)
+	expectSelected(2)
+	fetch(1)
+	expectSelected(2)
+	h.press(tea.KeyUp, tea.KeyUp)
+	expectSelected(1)
+
+	// Back on the newest request, the panel follows new ones again
+	h.press(tea.KeyDown, tea.KeyDown, tea.KeyDown)
+	if h.m.tracePinned {
+		t.Error("still pinned on the newest request")
+	}
+	fetch(2)
+	expectSelected(2)
+	if rows := len(h.m.tracer.Entries()); h.m.traceSelected(rows) != rows-1 {
+		t.Errorf("selected %d of %d requests; want the newest", h.m.traceSelected(rows), rows)
+	}
+
+	h.press(tea.KeyEsc)
+	if h.m.showTrace || h.quit {
+		t.Error("esc did not close the trace panel")
+	}
+}
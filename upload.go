@@ -0,0 +1,109 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// runUpload sends exported records to the media service or another file.
+func runUpload(args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: GoFetcher upload [flags] records.json|records.ndjson|records.csv")
+		fs.PrintDefaults()
+	}
+	mediaUrl := fs.String("media-url", services.MediaServiceUrl, "media service endpoint records are uploaded to")
+	token := fs.String("token", os.Getenv("GOFETCHER_TOKEN"), "media service token (defaults to $GOFETCHER_TOKEN)")
+	authorId := fs.Uint("author", 0, "author ID used for records that do not set authorId")
+	coverMode := fs.String("cover-mode", string(services.CoverFile), "how covers are uploaded: file (the image path of each record), stream (piped from imageUrl) or url (link only)")
+	allowMissing := fs.Bool("allow-missing-cover", false, "upload records without a cover when their image file is missing")
+	sink := fs.String("sink", "media", "where records go: media for the media service, or a .json, .ndjson or .csv file")
+	applyLog := logFlags(fs, "", true)
+	applyTrace := traceFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := applyLog(); err != nil {
+		return err
+	}
+	_, writeTrace := applyTrace()
+	defer func() {
+		if err := writeTrace(); err != nil {
+			slog.Error("writing trace failed", "err", err)
+		}
+	}()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("upload needs exactly one input file")
+	}
+	mode, err := services.ParseCoverMode(*coverMode)
+	if err != nil {
+		return err
+	}
+
+	requests, err := services.ReadExportFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for i := range requests {
+		if requests[i].AuthorId == 0 {
+			requests[i].AuthorId = uint(*authorId)
+		}
+	}
+
+	if *sink != "media" {
+		records := make([]services.ExportRecord, len(requests))
+		for i, request := range requests {
+			records[i].Request = request
+		}
+		if err := services.ExportFile(*sink, records, services.ExportOptions{}); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d records to %s\n", len(records), *sink)
+		return nil
+	}
+
+	var failed int
+	for _, request := range requests {
+		media := &services.MediaService{Url: *mediaUrl, Mode: mode, Images: services.ImageOptions{AllowMissing: *allowMissing}}
+		result := uploadRequest(request, *token, media)
+		if result.Err != nil {
+			failed++
+		}
+		fmt.Println(result.String())
+	}
+	fmt.Printf("Uploaded %d records, %d failed\n", len(requests)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d records failed to upload", failed)
+	}
+	return nil
+}
+
+// uploadRequest reports a missing author ID rather than sending it.
+func uploadRequest(request services.Request, token string, media *services.MediaService) services.ItemResult {
+	result := services.ItemResult{Title: request.Title, Request: &request}
+	if request.AuthorId == 0 {
+		result.Err = errors.New("no author ID, set -author or the authorId field")
+		return result
+	}
+	if media.Mode == services.CoverFile && request.Image != "" {
+		if _, err := os.Stat(request.Image); err != nil {
+			if !media.Images.AllowMissing {
+				result.Err = err
+				return result
+			}
+			result.CoverErr = err
+			request.Image = ""
+		}
+	}
+	var coverErr error
+	result.Err, coverErr = services.SplitCoverError(media.Send(request, token, nil))
+	if coverErr != nil {
+		result.CoverErr = coverErr
+	}
+	return result
+}
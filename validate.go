@@ -0,0 +1,84 @@
+package main
+
+import (
+	"GoFetcher/services"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func validateAuthorId(s string) (uint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("author ID is required")
+	}
+	id, err := strconv.ParseUint(s, 10, strconv.IntSize)
+	if err != nil {
+		return 0, errors.New("author ID must be a positive whole number")
+	}
+	if id == 0 {
+		return 0, errors.New("author ID must be greater than 0")
+	}
+	return uint(id), nil
+}
+
+func validateToken(s string) error {
+	if s == "" {
+		return errors.New("token is required")
+	}
+	if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+		return errors.New("token must not contain spaces")
+	}
+	return nil
+}
+
+// validateInput checks the input as typed, leaving empty fields for submit.
+func (m *model) validateInput() {
+	value := m.ti.Value()
+	m.inputErr = nil
+	if value == "" {
+		return
+	}
+	switch m.state {
+	case InputAuthorId:
+		_, m.inputErr = validateAuthorId(value)
+	case InputToken:
+		m.inputErr = validateToken(value)
+	}
+}
+
+func (m *model) lookupAuthor(id uint) tea.Cmd {
+	lookup, token := m.authorLookup, m.lookupToken
+	return func() tea.Msg {
+		name, err := services.LookupAuthor(lookup, id, token)
+		return authorMsg{id: id, name: name, err: err}
+	}
+}
+
+// inputStatus renders the line below the author ID or token input.
+func (m *model) inputStatus() string {
+	switch {
+	case m.inputErr != nil:
+		return m.theme.Error.Render("✗ " + m.inputErr.Error())
+	case m.state == InputAuthorId && m.checkingAuthor:
+		return m.spinner.View() + " Looking up author…"
+	case m.state == InputAuthorId && m.author.name != "" && m.ti.Value() == fmt.Sprint(m.author.id):
+		return m.theme.Success.Render("✓ " + m.author.name + " (enter to continue)")
+	case m.state == InputAuthorId && m.author.err != nil && m.ti.Value() == fmt.Sprint(m.author.id):
+		return m.theme.Warning.Render("! author not confirmed: " + m.author.err.Error() + " (enter to continue)")
+	}
+	return ""
+}
+
+// echoMode hides the token while it is typed.
+func echoMode(state State) textinput.EchoMode {
+	if state == InputToken {
+		return textinput.EchoPassword
+	}
+	return textinput.EchoNormal
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestValidateAuthorId(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		id    uint
+		err   string
+	}{
+		{"7", 7, ""},
+		{" 42 ", 42, ""},
+		{"", 0, "author ID is required"},
+		{"0", 0, "author ID must be greater than 0"},
+		{"-3", 0, "author ID must be a positive whole number"},
+		{"7x", 0, "author ID must be a positive whole number"},
+		{"99999999999999999999999", 0, "author ID must be a positive whole number"},
+	} {
+		id, err := validateAuthorId(tc.input)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if id != tc.id || got != tc.err {
+			t.Errorf("validateAuthorId(%q) = %d, %q; want %d, %q", tc.input, id, got, tc.id, tc.err)
+		}
+	}
+}